@@ -0,0 +1,289 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/javif89/dotenv"
+	"github.com/javif89/migrate"
+	"github.com/javif89/migrate/database"
+	"github.com/urfave/cli/v2"
+)
+
+const defaultMigrationsPath = "./database/migrations"
+
+var errNoDatabase = errors.New("no database configured. Set DATABASE_URL, pass --dsn or run migrate init")
+
+// Everything we need to build a migrate.Migrations
+type settings struct {
+	Path       string
+	Driver     database.DriverName
+	Config     database.Config
+	OutOfOrder migrate.OutOfOrderPolicy
+	// Environment we are running in and the ones where
+	// destructive commands need confirmation
+	Environment string
+	Protected   []string
+	// Keep each migration's down SQL in the migrations table
+	StoreDown bool
+	// Extra migration folders, e.g. one per module
+	ModulePaths []string
+	// Template variables for the migrations. Nil when there are none.
+	Vars map[string]string
+	// Lint rules to skip for the whole project
+	LintIgnore []string
+}
+
+// Values passed on the command line. Empty means not set.
+type flagValues struct {
+	DSN    string
+	Driver string
+	Path   string
+	Env    string
+	// Template variables as Name=value
+	Vars []string
+}
+
+// Settings are resolved from the following sources, first one wins:
+//
+//  1. Command line flags (--dsn, --driver, --path, --env, --var)
+//  2. Process environment variables
+//  3. The env file (.env or --env-file)
+//
+// If a DSN is found in any of them (--dsn or DATABASE_URL) it is used for
+// the connection and the individual DB_* variables are ignored, except for
+// the DB_TLS_* files. The driver is inferred from the DSN scheme unless
+// --driver is passed explicitly.
+func resolveSettings(flags flagValues, lookupEnv func(string) (string, bool), file *dotenv.EnvFile) (settings, error) {
+	get := func(key string) string {
+		if v, ok := lookupEnv(key); ok && v != "" {
+			return v
+		}
+
+		if file != nil {
+			return file.Get(key)
+		}
+
+		return ""
+	}
+
+	s := settings{
+		Path:       first(flags.Path, get("MIGRATIONS_PATH"), defaultMigrationsPath),
+		OutOfOrder: migrate.OutOfOrderPolicy(first(get("MIGRATIONS_OUT_OF_ORDER"), string(migrate.OutOfOrderWarn))),
+	}
+
+	s.Environment = first(flags.Env, get("MIGRATIONS_ENV"), get("APP_ENV"))
+	s.Protected = migrate.DefaultProtectedEnvironments
+
+	if paths := get("MIGRATIONS_MODULE_PATHS"); paths != "" {
+		for _, p := range strings.Split(paths, ",") {
+			s.ModulePaths = append(s.ModulePaths, strings.TrimSpace(p))
+		}
+	}
+
+	if rules := get("MIGRATIONS_LINT_IGNORE"); rules != "" {
+		for _, r := range strings.Split(rules, ",") {
+			s.LintIgnore = append(s.LintIgnore, strings.TrimSpace(r))
+		}
+	}
+
+	if envs := get("MIGRATIONS_PROTECTED_ENVS"); envs != "" {
+		s.Protected = []string{}
+		for _, env := range strings.Split(envs, ",") {
+			s.Protected = append(s.Protected, strings.TrimSpace(env))
+		}
+	}
+
+	// Name:value pairs, since env files can't have = in values
+	if vars := get("MIGRATIONS_VARS"); vars != "" {
+		for _, v := range strings.Split(vars, ",") {
+			name, value, ok := strings.Cut(v, ":")
+			if !ok {
+				return s, fmt.Errorf("invalid MIGRATIONS_VARS entry %q. Use Name:value", v)
+			}
+
+			s.setVar(name, value)
+		}
+	}
+
+	for _, v := range flags.Vars {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok {
+			return s, fmt.Errorf("invalid --var %q. Use Name=value", v)
+		}
+
+		s.setVar(name, value)
+	}
+
+	switch v := get("MIGRATIONS_STORE_DOWN"); strings.ToLower(v) {
+	case "", "false", "0":
+	case "true", "1":
+		s.StoreDown = true
+	default:
+		return s, fmt.Errorf("invalid MIGRATIONS_STORE_DOWN %q. Use true or false", v)
+	}
+
+	switch s.OutOfOrder {
+	case migrate.OutOfOrderWarn, migrate.OutOfOrderStrict, migrate.OutOfOrderAllow:
+	default:
+		return s, fmt.Errorf("invalid MIGRATIONS_OUT_OF_ORDER %q. Use strict, warn or allow", s.OutOfOrder)
+	}
+
+	if dsn := first(flags.DSN, get("DATABASE_URL")); dsn != "" {
+		driver, cfg, err := database.ParseURL(dsn)
+		if err != nil {
+			return s, err
+		}
+
+		s.Driver = database.DriverName(first(flags.Driver, string(driver)))
+		s.Config = cfg
+		s.Config.TLS = tlsConfig(get)
+
+		return s, nil
+	}
+
+	s.Driver = database.DriverName(first(flags.Driver, get("DB_DRIVER")))
+	s.Config = database.Config{
+		Host:     get("DB_HOST"),
+		Port:     get("DB_PORT"),
+		Username: get("DB_USERNAME"),
+		Password: get("DB_PASSWORD"),
+		Database: get("DB_DATABASE"),
+		TLS:      tlsConfig(get),
+	}
+
+	return s, nil
+}
+
+func (s *settings) setVar(name, value string) {
+	if s.Vars == nil {
+		s.Vars = map[string]string{}
+	}
+
+	s.Vars[strings.TrimSpace(name)] = strings.TrimSpace(value)
+}
+
+// TLS files apply to both DSNs and the DB_* variables
+func tlsConfig(get func(string) string) *database.TLSConfig {
+	t := database.TLSConfig{
+		CAFile:   get("DB_TLS_CA"),
+		CertFile: get("DB_TLS_CERT"),
+		KeyFile:  get("DB_TLS_KEY"),
+	}
+
+	if t == (database.TLSConfig{}) {
+		return nil
+	}
+
+	return &t
+}
+
+// Load settings for the current invocation
+func loadSettings(ctx *cli.Context) (settings, error) {
+	envFile := ctx.String("env-file")
+
+	var file *dotenv.EnvFile
+	if fileExists(envFile) {
+		file = dotenv.Load(envFile)
+	} else if ctx.IsSet("env-file") {
+		return settings{}, fmt.Errorf("env file %s not found", envFile)
+	}
+
+	return resolveSettings(flagValues{
+		DSN:    ctx.String("dsn"),
+		Driver: ctx.String("driver"),
+		Path:   ctx.String("path"),
+		Env:    ctx.String("env"),
+		Vars:   ctx.StringSlice("var"),
+	}, os.LookupEnv, file)
+}
+
+func newMigrations(ctx *cli.Context, opts ...migrate.Option) (*migrate.Migrations, error) {
+	s, err := loadSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Driver == "" {
+		return nil, errNoDatabase
+	}
+
+	base, err := migrationOptions(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.Open(s.Path, s.Driver, s.Config, append(base, opts...)...)
+}
+
+// Options shared by every command, built from the settings and global flags
+func migrationOptions(ctx *cli.Context, s settings) ([]migrate.Option, error) {
+	logger, err := newLogger(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	outOfOrder := s.OutOfOrder
+	if ctx.Bool("allow-out-of-order") {
+		outOfOrder = migrate.OutOfOrderAllow
+	}
+
+	opts := []migrate.Option{
+		migrate.WithLogger(logger),
+		migrate.WithOutOfOrder(outOfOrder),
+		migrate.WithEnvironment(s.Environment),
+		migrate.WithProtectedEnvironments(s.Protected...),
+	}
+
+	if s.StoreDown {
+		opts = append(opts, migrate.WithStoreDownSQL())
+	}
+
+	if len(s.ModulePaths) > 0 {
+		opts = append(opts, migrate.WithModulePaths(s.ModulePaths...))
+	}
+
+	if len(s.LintIgnore) > 0 {
+		opts = append(opts, migrate.WithLintIgnore(s.LintIgnore...))
+	}
+
+	if s.Vars != nil {
+		opts = append(opts, migrate.WithVars(s.Vars))
+	}
+
+	if tags := ctx.StringSlice("tag"); len(tags) > 0 {
+		opts = append(opts, migrate.WithTags(tags...))
+	}
+
+	return opts, nil
+}
+
+// Whether destructive commands need confirmation
+func (s settings) protected() bool {
+	for _, env := range s.Protected {
+		if s.Environment != "" && strings.EqualFold(env, s.Environment) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Return the first non empty value
+func first(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+
+	return err == nil
+}
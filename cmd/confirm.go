@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+var errAborted = errors.New("aborted")
+
+// Destructive commands in a protected environment need --force or the
+// user typing the environment name back. Without a terminal to ask on
+// we refuse.
+func confirmDestructive(ctx *cli.Context, command string) error {
+	s, err := loadSettings(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !s.protected() || ctx.Bool("force") {
+		return nil
+	}
+
+	errForce := fmt.Errorf("%s is a protected environment. Pass --force to run %s", s.Environment, command)
+
+	if !isTerminal(os.Stdin) {
+		return errForce
+	}
+
+	fmt.Printf("You are about to run %s in %s (%s).\n", command, s.Environment, s.Config.Database)
+	fmt.Printf("Type %s to continue: ", s.Environment)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+
+	// Nobody there to answer, e.g. stdin is /dev/null
+	if err != nil && answer == "" {
+		fmt.Println()
+		return errForce
+	}
+
+	if strings.TrimSpace(answer) != s.Environment {
+		return errAborted
+	}
+
+	return nil
+}
+
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return stat.Mode()&os.ModeCharDevice != 0
+}
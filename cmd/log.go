@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/urfave/cli/v2"
+)
+
+// Build the logger from --quiet, --verbose and --log-format
+func newLogger(ctx *cli.Context) (*slog.Logger, error) {
+	level := slog.LevelInfo
+
+	if ctx.Bool("verbose") {
+		level = slog.LevelDebug
+	}
+
+	// Quiet still lets errors through
+	if ctx.Bool("quiet") {
+		level = slog.LevelError
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	switch ctx.String("log-format") {
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stdout, opts)), nil
+	case "text":
+		// Timestamps are just noise when running in a terminal
+		opts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+
+			return a
+		}
+
+		return slog.New(slog.NewTextHandler(os.Stdout, opts)), nil
+	}
+
+	return nil, fmt.Errorf("invalid log format %q. Use text or json", ctx.String("log-format"))
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/javif89/migrate"
+)
+
+// Print one line per migration, e.g.
+//
+//	Ran?     Batch  Migration                              Notes
+//	Yes      1      2024_01_01_000000_create_users_table
+//	Missing  1      2024_01_02_000000_add_email_to_users   can't be rolled back
+//	No              2024_01_03_000000_create_posts_table   irreversible
+func printStatus(w io.Writer, statuses []migrate.MigrationStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "Ran?\tBatch\tMigration\tNotes")
+
+	for _, s := range statuses {
+		ran, batch := "No", ""
+		notes := []string{}
+
+		if s.Applied {
+			ran, batch = "Yes", strconv.Itoa(s.Batch)
+		}
+
+		if s.Repeatable {
+			batch = ""
+			notes = append(notes, "repeatable")
+		}
+
+		if s.Outdated {
+			notes = append(notes, "changed, runs again on the next migrate")
+		}
+
+		if s.Missing {
+			ran = "Missing"
+
+			if !s.DownStored && !s.Repeatable {
+				notes = append(notes, "can't be rolled back")
+			}
+		}
+
+		if s.Irreversible {
+			notes = append(notes, "irreversible")
+		}
+
+		if s.EmptyDown {
+			notes = append(notes, "no down section")
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", ran, batch, s.Name, strings.Join(notes, ", "))
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/javif89/migrate"
+	"github.com/javif89/migrate/database"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Layout of the --tenants file. Environment variables in the values
+// are expanded, so passwords can stay out of the file. A $ that isn't
+// followed by a set variable is kept as is:
+//
+//	concurrency: 4
+//	tenants:
+//	  - name: acme
+//	    dsn: sqlite://tenants/acme.sqlite
+//	  - name: globex
+//	    driver: mysql
+//	    host: db.internal
+//	    port: "3306"
+//	    username: globex
+//	    password: ${GLOBEX_DB_PASSWORD}
+//	    database: globex
+type tenantsFile struct {
+	Concurrency int           `yaml:"concurrency"`
+	Tenants     []tenantEntry `yaml:"tenants"`
+}
+
+type tenantEntry struct {
+	Name     string `yaml:"name"`
+	DSN      string `yaml:"dsn"`
+	Driver   string `yaml:"driver"`
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Database string `yaml:"database"`
+}
+
+func loadTenants(path string) (tenantsFile, []migrate.Tenant, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return tenantsFile{}, nil, err
+	}
+
+	var f tenantsFile
+	if err := yaml.Unmarshal(content, &f); err != nil {
+		return f, nil, fmt.Errorf("invalid tenants file %s: %w", path, err)
+	}
+
+	tenants := []migrate.Tenant{}
+
+	for i, e := range f.Tenants {
+		for _, v := range []*string{&e.Name, &e.DSN, &e.Driver, &e.Host, &e.Port, &e.Username, &e.Password, &e.Database} {
+			*v = expandEnv(*v)
+		}
+
+		t := migrate.Tenant{
+			Name:   e.Name,
+			Driver: database.DriverName(e.Driver),
+			Config: database.Config{
+				Host:     e.Host,
+				Port:     e.Port,
+				Username: e.Username,
+				Password: e.Password,
+				Database: e.Database,
+			},
+		}
+
+		if t.Name == "" {
+			t.Name = fmt.Sprintf("tenant %d", i+1)
+		}
+
+		if e.DSN != "" {
+			driver, cfg, err := database.ParseURL(e.DSN)
+			if err != nil {
+				return f, nil, fmt.Errorf("%s: %w", t.Name, err)
+			}
+
+			t.Driver = database.DriverName(first(e.Driver, string(driver)))
+			t.Config = cfg
+		}
+
+		if t.Driver == "" {
+			return f, nil, fmt.Errorf("%s: no driver or dsn", t.Name)
+		}
+
+		tenants = append(tenants, t)
+	}
+
+	return f, tenants, nil
+}
+
+var envPattern = regexp.MustCompile(`\$\{(\w+)\}|\$(\w+)`)
+
+// Replace $NAME and ${NAME} with environment variables that are
+// set, leaving anything else alone, e.g. a $ in a password
+func expandEnv(s string) string {
+	return envPattern.ReplaceAllStringFunc(s, func(match string) string {
+		m := envPattern.FindStringSubmatch(match)
+
+		if v, ok := os.LookupEnv(m[1] + m[2]); ok {
+			return v
+		}
+
+		return match
+	})
+}
+
+// Run migrations or rollbacks across every tenant in the --tenants file
+func runTenants(ctx *cli.Context, dir migrate.Direction) error {
+	s, err := loadSettings(ctx)
+	if err != nil {
+		return err
+	}
+
+	f, tenants, err := loadTenants(ctx.String("tenants"))
+	if err != nil {
+		return err
+	}
+
+	opts, err := migrationOptions(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	logger, _ := newLogger(ctx)
+
+	concurrency := f.Concurrency
+	if ctx.IsSet("concurrency") {
+		concurrency = ctx.Int("concurrency")
+	}
+
+	r := &migrate.Runner{
+		Path:        s.Path,
+		Tenants:     tenants,
+		Concurrency: concurrency,
+		FailFast:    ctx.Bool("fail-fast"),
+		Options:     append(opts, migrate.WithAllowDestructive()),
+		Logger:      logger,
+	}
+
+	run := r.Migrate
+	if dir == migrate.Down {
+		run = r.Rollback
+	}
+
+	results, err := run(context.Background())
+
+	failed := 0
+	for _, res := range results {
+		switch {
+		case res.Skipped:
+			logger.Warn("tenant skipped", "tenant", res.Tenant.Name)
+		case res.Err != nil:
+			failed++
+			logger.Error("tenant failed", "tenant", res.Tenant.Name, "duration", res.Duration, "error", res.Err)
+		default:
+			logger.Info("tenant done", "tenant", res.Tenant.Name, "duration", res.Duration)
+		}
+	}
+
+	if err != nil && failed > 0 {
+		return fmt.Errorf("%d of %d tenants failed", failed, len(results))
+	}
+
+	return err
+}
@@ -1,11 +1,100 @@
 package database
 
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
 type Config struct {
 	Username string
 	Password string
+	// For MySQL a host starting with / is a unix socket
 	Host string
 	Port string
 	Database string
+
+	// Extra driver parameters added to the DSN. For MySQL these are the
+	// usual DSN params (parseTime, charset, collation, timeout,
+	// multiStatements...). For SQLite they are added to the file URI.
+	Params map[string]string
+
+	// Optional TLS settings. Only used by MySQL.
+	TLS *TLSConfig
+
+	// Full DSN passed straight to the driver, e.g.
+	// file:app.db?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)
+	// for SQLite. When set the connection fields above are ignored.
+	DSN string
+}
+
+type TLSConfig struct {
+	CAFile string
+	// Client certificate and key, for mutual TLS
+	CertFile string
+	KeyFile string
+	// Defaults to the host we are connecting to
+	ServerName string
+	InsecureSkipVerify bool
+}
+
+// Params as a query string, sorted so the DSN is stable. Values are
+// not escaped since drivers expect things like charset=utf8mb4,utf8
+// as is.
+func (c Config) encodeParams() string {
+	keys := []string{}
+	for k := range c.Params {
+		keys = append(keys, k)
+	}
+
+	slices.Sort(keys)
+
+	params := []string{}
+	for _, k := range keys {
+		params = append(params, k+"="+c.Params[k])
+	}
+
+	return strings.Join(params, "&")
+}
+
+func (t *TLSConfig) load(serverName string) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName: serverName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.ServerName != "" {
+		config.ServerName = t.ServerName
+	}
+
+	if t.CAFile != "" {
+		ca, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", t.CAFile)
+		}
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
 }
 
 type DriverName string
@@ -14,13 +103,80 @@ type DriverName string
 var DriverMysql DriverName = "mysql"
 var DriverSqlite DriverName = "sqlite"
 
-var Drivers map[DriverName]Driver = map[DriverName]Driver {
-	DriverMysql: MysqlDriver{},
-	DriverSqlite: SQLiteDriver{},
+var ErrUnknownDriver error = errors.New("unknown driver")
+
+// Creates a new driver, ready to be opened
+type Factory func() Driver
+
+var driversMu sync.RWMutex
+var drivers = map[DriverName]Factory{}
+
+// Register makes a driver available by name, in the same spirit
+// as database/sql. Out of tree drivers should call it from an
+// init function. Registering the same name twice panics. Drivers
+// whose placeholders aren't ? should implement Binder.
+func Register(name DriverName, factory Factory) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+
+	if factory == nil {
+		panic("database: Register factory is nil")
+	}
+
+	if _, dup := drivers[name]; dup {
+		panic("database: Register called twice for driver " + name)
+	}
+
+	drivers[name] = factory
+}
+
+// Drivers returns the sorted names of the registered drivers
+func Drivers() []DriverName {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+
+	names := []DriverName{}
+	for name := range drivers {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+func newDriver(name DriverName) (Driver, error) {
+	driversMu.RLock()
+	factory, ok := drivers[name]
+	driversMu.RUnlock()
+
+	if !ok {
+		registered := []string{}
+		for _, n := range Drivers() {
+			registered = append(registered, string(n))
+		}
+
+		return nil, fmt.Errorf("%w %q (registered: %s)", ErrUnknownDriver, name, strings.Join(registered, ", "))
+	}
+
+	return factory(), nil
 }
 
 func GetDriver(driver DriverName, cfg Config) (Driver, error) {
-	d := Drivers[driver]
+	d, err := newDriver(driver)
+	if err != nil {
+		return nil, err
+	}
 
 	return d.Open(cfg)
-}
\ No newline at end of file
+}
+
+// Get a driver that runs on an existing connection
+func WrapDriver(driver DriverName, db *sql.DB, cfg Config) (Driver, error) {
+	d, err := newDriver(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.Wrap(db, cfg), nil
+}
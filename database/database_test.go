@@ -0,0 +1,76 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Stand in for an out of tree driver
+type fakeDriver struct {
+	cfg Config
+}
+
+func (f fakeDriver) Open(cfg Config) (Driver, error)          { return fakeDriver{cfg: cfg}, nil }
+func (f fakeDriver) Wrap(db *sql.DB, cfg Config) Driver       { return fakeDriver{cfg: cfg} }
+func (f fakeDriver) Close() error                             { return nil }
+func (f fakeDriver) GetConnection() *sql.DB                   { return nil }
+func (f fakeDriver) Run(query string) error                   { return nil }
+func (f fakeDriver) Wipe(opts WipeOptions) error              { return nil }
+func (f fakeDriver) CreateMigrationsTable(table string) error { return nil }
+
+func TestRegister(t *testing.T) {
+	Register("fake", func() Driver {
+		return fakeDriver{}
+	})
+
+	d, err := GetDriver("fake", Config{Database: "fakedb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.(fakeDriver).cfg.Database != "fakedb" {
+		t.Errorf("Registered driver was not opened with the config")
+	}
+
+	names := Drivers()
+	if len(names) != 3 || names[0] != "fake" || names[1] != DriverMysql || names[2] != DriverSqlite {
+		t.Errorf("Incorrect registered drivers %v", names)
+	}
+
+	driver, cfg, err := ParseURL("fake://user@host:9000/fakedb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if driver != "fake" || cfg.Port != "9000" || cfg.Database != "fakedb" {
+		t.Errorf("Registered driver names should work as url schemes: %s %+v", driver, cfg)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Registering a driver twice should panic")
+		}
+	}()
+
+	Register("fake", func() Driver {
+		return fakeDriver{}
+	})
+}
+
+func TestUnknownDriver(t *testing.T) {
+	_, err := GetDriver("mysqll", Config{})
+
+	if !errors.Is(err, ErrUnknownDriver) {
+		t.Fatalf("Expected an unknown driver error, got %v", err)
+	}
+
+	if !strings.Contains(err.Error(), "mysql, sqlite") {
+		t.Errorf("Error should list the registered drivers: %s", err)
+	}
+
+	if _, err := WrapDriver("mysqll", nil, Config{}); !errors.Is(err, ErrUnknownDriver) {
+		t.Errorf("Expected an unknown driver error, got %v", err)
+	}
+}
@@ -4,7 +4,11 @@ import "database/sql"
 
 type Driver interface {
 	Open(cfg Config) (Driver, error)
+	// Wrap returns a driver that uses an existing connection
+	// instead of opening its own.
+	Wrap(db *sql.DB, cfg Config) Driver
 	// Close closes the underlying database instance managed by the driver.
+	// Connections passed in through Wrap are left open for the caller.
 	// Migrate will call this function only once per instance.
 	Close() error
 
@@ -16,8 +20,19 @@ type Driver interface {
 	// Run applies a migration to the database. migration is guaranteed to be not nil.
 	Run(query string) error
 
-	// Wipe deletes everything in the database.
-	Wipe() error
+	// Wipe drops every table, view, index, trigger and routine in the
+	// database, except for the ones excluded by opts. It keeps going
+	// when a drop fails and returns all the errors it ran into.
+	Wipe(opts WipeOptions) error
 
-	CreateMigrationsTable() error
-}
\ No newline at end of file
+	// Create the table used to keep track of migrations
+	// if it doesn't exist yet
+	CreateMigrationsTable(table string) error
+}
+
+// Drivers whose query placeholders aren't ?, e.g. $1 for Postgres.
+// Drivers that don't implement it get ?.
+type Binder interface {
+	// Placeholder for the nth argument of a query, starting at 1
+	Placeholder(n int) string
+}
@@ -1,8 +1,12 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
@@ -11,39 +15,121 @@ import (
 type MysqlDriver struct {
 	conn *sql.DB
 	config Config
+	// Whether we opened conn ourselves. Connections passed
+	// in through Wrap belong to the caller.
+	owned bool
 }
 
-func (m MysqlDriver) Open(cfg Config) (Driver, error) {
-	config := mysql.Config{
-		User:   cfg.Username,
-		Passwd: cfg.Password,
-		Net:    "tcp",
-		Addr:   fmt.Sprintf("%s:%s", cfg.Host, cfg.Port),
-		DBName: cfg.Database,
+func init() {
+	Register(DriverMysql, func() Driver {
+		return &MysqlDriver{}
+	})
+}
+
+func (m *MysqlDriver) Open(cfg Config) (Driver, error) {
+	config, err := mysqlConfig(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	db, err := sql.Open("mysql", config.FormatDSN())
+	d, err := openMysql(config, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func openMysql(config *mysql.Config, cfg Config) (*MysqlDriver, error) {
+	connector, err := mysql.NewConnector(config)
 	if err != nil {
 		return nil, err
 	}
+
+	db := sql.OpenDB(connector)
 	// See "Important settings" section.
 	db.SetConnMaxLifetime(time.Minute * 3)
 	db.SetMaxOpenConns(10)
 	db.SetMaxIdleConns(10)
 
-	d := MysqlDriver{
+	// Wipe needs the database name, which might only be in the DSN
+	cfg.Database = config.DBName
+
+	d := &MysqlDriver{
 		conn: db,
 		config: cfg,
+		owned: true,
 	}
 
 	return d, nil
 }
 
-func (m MysqlDriver) Close() error {
-	return nil
+// Build the mysql driver config. A DSN is used as is, otherwise it's
+// built from the individual fields. Params and TLS are applied on top.
+func mysqlConfig(cfg Config) (*mysql.Config, error) {
+	dsn := cfg.DSN
+
+	if dsn == "" {
+		config := mysql.NewConfig()
+		config.User = cfg.Username
+		config.Passwd = cfg.Password
+		config.DBName = cfg.Database
+		config.Net = "tcp"
+		config.Addr = fmt.Sprintf("%s:%s", cfg.Host, cfg.Port)
+
+		// Hosts that look like a path are unix sockets
+		if strings.HasPrefix(cfg.Host, "/") {
+			config.Net = "unix"
+			config.Addr = cfg.Host
+		}
+
+		dsn = config.FormatDSN()
+	}
+
+	// Let the mysql driver parse the params so we get the
+	// same validation and names as a regular DSN
+	if params := cfg.encodeParams(); params != "" {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+
+		dsn += sep + params
+	}
+
+	config, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.TLS != nil {
+		serverName, _, _ := strings.Cut(config.Addr, ":")
+
+		config.TLS, err = cfg.TLS.load(serverName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return config, nil
+}
+
+func (m *MysqlDriver) Wrap(db *sql.DB, cfg Config) Driver {
+	return &MysqlDriver{
+		conn: db,
+		config: cfg,
+	}
+}
+
+func (m *MysqlDriver) Close() error {
+	if !m.owned {
+		return nil
+	}
+
+	return m.conn.Close()
 }
 
-func (m MysqlDriver) Run(query string) error {
+func (m *MysqlDriver) Run(query string) error {
 	_, err := m.conn.Exec(query)
 
 	if err != nil {
@@ -53,56 +139,268 @@ func (m MysqlDriver) Run(query string) error {
 	return nil
 }
 
-func (m MysqlDriver) Wipe() error {
-	// Get all the table drop commands
-	q := fmt.Sprintf(`
-		SELECT table_name
-		FROM information_schema.tables
-		WHERE table_schema = '%s';
-	`, m.config.Database)
+// Objects are dropped in this order
+var mysqlDropOrder = []string{"event", "procedure", "function", "trigger", "view", "table"}
+
+// Queries to list each kind of object in a schema
+var mysqlObjectQueries = []string{
+	"SELECT 'event', event_name, NULL FROM information_schema.events WHERE event_schema = ?",
+	"SELECT routine_type, routine_name, NULL FROM information_schema.routines WHERE routine_schema = ?",
+	"SELECT 'trigger', trigger_name, event_object_table FROM information_schema.triggers WHERE trigger_schema = ?",
+	"SELECT 'view', table_name, NULL FROM information_schema.views WHERE table_schema = ?",
+	"SELECT 'table', table_name, NULL FROM information_schema.tables WHERE table_schema = ? AND table_type = 'BASE TABLE'",
+}
 
-	rows, err := m.conn.Query(q)
+func (m *MysqlDriver) Wipe(opts WipeOptions) error {
+	ctx := context.Background()
 
+	// Foreign key checks are per session so everything
+	// has to happen on the same connection
+	conn, err := m.conn.Conn(ctx)
 	if err != nil {
 		return err
 	}
 
-	defer rows.Close()
+	defer conn.Close()
 
-	// Disable foreign keys
-	m.conn.Exec("SET FOREIGN_KEY_CHECKS = 0;")
+	schema := opts.Schema
+	if schema == "" {
+		schema = m.config.Database
+	}
 
-	// Delete all tables
-	for rows.Next() {
-		var t string
-		rows.Scan(&t)
-		m.conn.Exec(fmt.Sprintf("DROP TABLE IF EXISTS `%s`", t))
+	// Wrapped connections might not have the database name
+	// in the config, so fall back to the current database
+	if schema == "" {
+		if err := conn.QueryRowContext(ctx, "SELECT DATABASE()").Scan(&schema); err != nil {
+			return err
+		}
+	}
+
+	objects := []dbObject{}
+
+	for _, q := range mysqlObjectQueries {
+		o, err := queryObjects(ctx, conn, q, schema)
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, o...)
 	}
 
-	if rows.Err() != nil {
-		return rows.Err()
+	if _, err := conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS = 0"); err != nil {
+		return err
 	}
 
-	// Enable foreign keys
-	m.conn.Exec("SET FOREIGN_KEY_CHECKS = 1;")
+	errs := []error{}
 
-	return nil
+	for _, obj := range opts.dropOrder(objects, mysqlDropOrder) {
+		q := fmt.Sprintf("DROP %s IF EXISTS %s.%s", strings.ToUpper(obj.Kind), quoteIdent(schema, "`"), quoteIdent(obj.Name, "`"))
+
+		if _, err := conn.ExecContext(ctx, q); err != nil {
+			errs = append(errs, fmt.Errorf("failed dropping %s %s: %w", obj.Kind, obj.Name, err))
+		}
+	}
+
+	if _, err := conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS = 1"); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
 }
 
-func (m MysqlDriver) CreateMigrationsTable() error {
-	_, err := m.conn.Exec(`
-		create table if not exists migrations (
+func (m *MysqlDriver) CreateMigrationsTable(table string) error {
+	_, err := m.conn.Exec(fmt.Sprintf(`
+		create table if not exists %s (
 			id bigint NOT NULL AUTO_INCREMENT,
 			migration varchar(255),
 			batch int,
 
 			primary key (id)
 		)
-	`)
+	`, table))
 
 	return err
 }
 
-func (m MysqlDriver) GetConnection() *sql.DB {
+func (m *MysqlDriver) GetConnection() *sql.DB {
 	return m.conn
-}
\ No newline at end of file
+}
+
+// Column defaults that aren't string literals
+var mysqlDefaultExpression = regexp.MustCompile(`(?i)^(-?[0-9.]+|null|current_timestamp(\([0-9]*\))?|b'[01]*'|\(.*\))$`)
+
+func (m *MysqlDriver) Inspect(schema string) (*Schema, error) {
+	if schema == "" {
+		if err := m.conn.QueryRow("SELECT DATABASE()").Scan(&schema); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := m.conn.Query("SELECT table_name FROM information_schema.tables WHERE table_schema = ? AND table_type = 'BASE TABLE'", schema)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := scanStrings(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Schema{}
+	tables := map[string]*Table{}
+
+	for _, name := range names {
+		s.Tables = append(s.Tables, Table{Name: name})
+	}
+
+	for i := range s.Tables {
+		t := &s.Tables[i]
+		tables[t.Name] = t
+
+		var name string
+		if err := m.conn.QueryRow(fmt.Sprintf("SHOW CREATE TABLE %s.%s", quoteIdent(schema, "`"), quoteIdent(t.Name, "`"))).Scan(&name, &t.SQL); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err = m.conn.Query(`SELECT table_name, column_name, column_type, is_nullable = 'YES', column_default
+		FROM information_schema.columns WHERE table_schema = ? ORDER BY table_name, ordinal_position`, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var table string
+		var c Column
+
+		if err := rows.Scan(&table, &c.Name, &c.Type, &c.Nullable, &c.Default); err != nil {
+			return nil, err
+		}
+
+		// MySQL reports literal defaults without quotes
+		if c.Default.Valid && !mysqlDefaultExpression.MatchString(c.Default.String) {
+			c.Default.String = "'" + strings.ReplaceAll(c.Default.String, "'", "''") + "'"
+		}
+
+		if t, ok := tables[table]; ok {
+			t.Columns = append(t.Columns, c)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Primary keys are part of the table, not an index
+	rows, err = m.conn.Query(`SELECT table_name, index_name, non_unique = 0, coalesce(column_name, '<expression>')
+		FROM information_schema.statistics WHERE table_schema = ? AND index_name != 'PRIMARY'
+		ORDER BY table_name, index_name, seq_in_index`, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var table, column string
+		var i Index
+
+		if err := rows.Scan(&table, &i.Name, &i.Unique, &column); err != nil {
+			return nil, err
+		}
+
+		t, ok := tables[table]
+		if !ok {
+			continue
+		}
+
+		if n := len(t.Indexes); n == 0 || t.Indexes[n-1].Name != i.Name {
+			t.Indexes = append(t.Indexes, i)
+		}
+
+		t.Indexes[len(t.Indexes)-1].Columns = append(t.Indexes[len(t.Indexes)-1].Columns, column)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = m.conn.Query(`SELECT table_name, constraint_name, column_name, referenced_table_name, referenced_column_name
+		FROM information_schema.key_column_usage WHERE table_schema = ? AND referenced_table_name IS NOT NULL
+		ORDER BY table_name, constraint_name, ordinal_position`, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var table, name, column, refTable, refColumn string
+
+		if err := rows.Scan(&table, &name, &column, &refTable, &refColumn); err != nil {
+			return nil, err
+		}
+
+		t, ok := tables[table]
+		if !ok {
+			continue
+		}
+
+		if n := len(t.ForeignKeys); n == 0 || t.ForeignKeys[n-1].Name != name {
+			t.ForeignKeys = append(t.ForeignKeys, ForeignKey{Name: name, RefTable: refTable})
+		}
+
+		fk := &t.ForeignKeys[len(t.ForeignKeys)-1]
+		fk.Columns = append(fk.Columns, column)
+		fk.RefColumns = append(fk.RefColumns, refColumn)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	s.sort()
+
+	return s, nil
+}
+
+// A temporary schema on the same server, dropped by the returned function.
+// The user needs permission to create databases. Not supported for
+// connections passed in through Wrap since we don't know how to open
+// another one.
+func (m *MysqlDriver) Scratch() (Driver, func() error, error) {
+	if !m.owned {
+		return nil, nil, fmt.Errorf("%w: scratch databases need a connection opened by migrate, not one passed in", ErrNotSupported)
+	}
+
+	config, err := mysqlConfig(m.config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name := fmt.Sprintf("migrate_scratch_%d", time.Now().UnixNano())
+
+	if _, err := m.conn.Exec("CREATE DATABASE " + quoteIdent(name, "`")); err != nil {
+		return nil, nil, err
+	}
+
+	drop := func() error {
+		_, err := m.conn.Exec("DROP DATABASE IF EXISTS " + quoteIdent(name, "`"))
+		return err
+	}
+
+	cfg := m.config
+	cfg.Database = name
+	config.DBName = name
+
+	d, err := openMysql(config, cfg)
+	if err != nil {
+		return nil, nil, errors.Join(err, drop())
+	}
+
+	return d, func() error {
+		return errors.Join(d.Close(), drop())
+	}, nil
+}
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMysqlConfig(t *testing.T) {
+	config, err := mysqlConfig(Config{
+		Username: "user",
+		Password: "pass",
+		Host:     "db.internal",
+		Port:     "3307",
+		Database: "app",
+		Params: map[string]string{
+			"parseTime":       "true",
+			"multiStatements": "true",
+			"charset":         "utf8mb4",
+			"timeout":         "5s",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Addr != "db.internal:3307" || config.DBName != "app" || config.User != "user" {
+		t.Errorf("Connection fields not applied: %+v", config)
+	}
+
+	if !config.ParseTime || !config.MultiStatements || config.Timeout != 5*time.Second {
+		t.Errorf("Params not applied: %+v", config)
+	}
+
+	if config.Params["charset"] != "utf8mb4" {
+		t.Errorf("charset not applied: %v", config.Params)
+	}
+
+	if !config.AllowNativePasswords {
+		t.Errorf("Driver defaults should be kept")
+	}
+}
+
+func TestMysqlConfigSocket(t *testing.T) {
+	config, err := mysqlConfig(Config{Host: "/var/run/mysqld/mysqld.sock", Database: "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Net != "unix" || config.Addr != "/var/run/mysqld/mysqld.sock" {
+		t.Errorf("Expected a unix socket, got %s %s", config.Net, config.Addr)
+	}
+}
+
+func TestMysqlConfigDSN(t *testing.T) {
+	config, err := mysqlConfig(Config{
+		Host: "ignored",
+		DSN:  "user:pass@tcp(db.internal:3306)/app?parseTime=true",
+		Params: map[string]string{
+			"collation": "utf8mb4_unicode_ci",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Addr != "db.internal:3306" || config.DBName != "app" || !config.ParseTime {
+		t.Errorf("DSN was not used as is: %+v", config)
+	}
+
+	if config.Collation != "utf8mb4_unicode_ci" {
+		t.Errorf("Params should be applied on top of the DSN, got collation %s", config.Collation)
+	}
+
+	if _, err := mysqlConfig(Config{DSN: "not a dsn"}); err == nil {
+		t.Errorf("Expected an error for an invalid DSN")
+	}
+}
+
+func TestMysqlConfigTLS(t *testing.T) {
+	d := t.TempDir()
+	certFile, keyFile := writeCertificate(t, d)
+
+	config, err := mysqlConfig(Config{
+		Host:     "db.internal",
+		Port:     "3306",
+		Database: "app",
+		TLS: &TLSConfig{
+			CAFile:   certFile,
+			CertFile: certFile,
+			KeyFile:  keyFile,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.TLS == nil || config.TLS.RootCAs == nil || len(config.TLS.Certificates) != 1 {
+		t.Fatalf("TLS config not loaded: %+v", config.TLS)
+	}
+
+	if config.TLS.ServerName != "db.internal" {
+		t.Errorf("Server name should default to the host, got %s", config.TLS.ServerName)
+	}
+
+	_, err = mysqlConfig(Config{TLS: &TLSConfig{CAFile: filepath.Join(d, "missing.pem")}})
+	if err == nil {
+		t.Errorf("Expected an error for a missing CA file")
+	}
+}
+
+// Write a self signed certificate and its key
+func writeCertificate(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "db.internal"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	cert, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0600)
+
+	return certFile, keyFile
+}
+
+func TestMysqlDefaultExpression(t *testing.T) {
+	for value, expression := range map[string]bool{
+		"0":                    true,
+		"-1.5":                 true,
+		"CURRENT_TIMESTAMP":    true,
+		"current_timestamp(6)": true,
+		"(uuid())":             true,
+		"guest":                false,
+		"2024-01-01":           false,
+	} {
+		if mysqlDefaultExpression.MatchString(value) != expression {
+			t.Errorf("Incorrect match for %q", value)
+		}
+	}
+}
+
+func TestMysqlScratchWrapped(t *testing.T) {
+	d, err := WrapDriver(DriverMysql, nil, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := d.(Scratcher).Scratch(); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Scratch needs a connection we opened, got %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+)
+
+var ErrNotSupported error = errors.New("not supported by this driver")
+
+// Drivers that can describe the tables in their database
+type Inspector interface {
+	// Tables, columns, indexes and foreign keys in the schema. Empty
+	// means the database we are connected to for MySQL and "main"
+	// for SQLite.
+	Inspect(schema string) (*Schema, error)
+}
+
+// Drivers that can create a throwaway database, e.g. to see what
+// the migrations produce without touching the real one
+type Scratcher interface {
+	// An open driver on a new, empty database and a function
+	// that closes and deletes it
+	Scratch() (Driver, func() error, error)
+}
+
+// Drivers that can copy their database, e.g. to try
+// migrations on the copy first
+type Copier interface {
+	// An open driver on a copy of the database, schema and
+	// data, and a function that closes and deletes it
+	Copy() (Driver, func() error, error)
+}
+
+// Tables in a database, sorted by name. Views are left out.
+type Schema struct {
+	Tables []Table
+}
+
+type Table struct {
+	Name string
+	// CREATE TABLE statement as the database reports it
+	SQL     string
+	Columns []Column
+	// Sorted by name
+	Indexes     []Index
+	ForeignKeys []ForeignKey
+}
+
+type Column struct {
+	Name string
+	// As the database reports it, e.g. varchar(255)
+	Type     string
+	Nullable bool
+	// SQL expression, e.g. 'guest' for a string
+	Default sql.NullString
+}
+
+type Index struct {
+	Name    string
+	Columns []string
+	Unique  bool
+}
+
+// Foreign keys are told apart by what they point at since
+// SQLite doesn't keep their names
+type ForeignKey struct {
+	Name       string
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+}
+
+// Find a table by name, nil if there isn't one
+func (s *Schema) Table(name string) *Table {
+	for i := range s.Tables {
+		if s.Tables[i].Name == name {
+			return &s.Tables[i]
+		}
+	}
+
+	return nil
+}
+
+func (t *Table) Column(name string) *Column {
+	for i := range t.Columns {
+		if t.Columns[i].Name == name {
+			return &t.Columns[i]
+		}
+	}
+
+	return nil
+}
+
+func (t *Table) Index(name string) *Index {
+	for i := range t.Indexes {
+		if t.Indexes[i].Name == name {
+			return &t.Indexes[i]
+		}
+	}
+
+	return nil
+}
+
+// Foreign key with the same columns and target, nil if there isn't one
+func (t *Table) ForeignKey(fk ForeignKey) *ForeignKey {
+	for i := range t.ForeignKeys {
+		if t.ForeignKeys[i].String() == fk.String() {
+			return &t.ForeignKeys[i]
+		}
+	}
+
+	return nil
+}
+
+func (c Column) String() string {
+	s := c.Type
+
+	if !c.Nullable {
+		s += " not null"
+	}
+
+	if c.Default.Valid {
+		s += " default " + c.Default.String
+	}
+
+	return s
+}
+
+func (i Index) String() string {
+	s := "(" + strings.Join(i.Columns, ", ") + ")"
+
+	if i.Unique {
+		s = "unique " + s
+	}
+
+	return s
+}
+
+func (fk ForeignKey) String() string {
+	return "(" + strings.Join(fk.Columns, ", ") + ") references " + fk.RefTable + " (" + strings.Join(fk.RefColumns, ", ") + ")"
+}
+
+// Sort tables and indexes so schemas can be compared
+func (s *Schema) sort() {
+	slices.SortFunc(s.Tables, func(a Table, b Table) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	for _, t := range s.Tables {
+		slices.SortFunc(t.Indexes, func(a Index, b Index) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+	}
+}
+
+// Read the first column of every row
+func scanStrings(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+
+	values := []string{}
+
+	for rows.Next() {
+		var v string
+
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+
+		values = append(values, v)
+	}
+
+	return values, rows.Err()
+}
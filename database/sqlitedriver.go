@@ -1,8 +1,13 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	_ "github.com/ncruces/go-sqlite3/driver"
@@ -12,10 +17,33 @@ import (
 type SQLiteDriver struct {
 	conn *sql.DB
 	config Config
+	// Whether we opened conn ourselves. Connections passed
+	// in through Wrap belong to the caller.
+	owned bool
 }
 
-func (m SQLiteDriver) Open(cfg Config) (Driver, error) {
-	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_txlock=immediate", cfg.Database))
+func init() {
+	Register(DriverSqlite, func() Driver {
+		return &SQLiteDriver{}
+	})
+}
+
+func (m *SQLiteDriver) Open(cfg Config) (Driver, error) {
+	dsn := cfg.DSN
+	if dsn == "" {
+		dsn = fmt.Sprintf("file:%s?_txlock=immediate", cfg.Database)
+	}
+
+	if params := cfg.encodeParams(); params != "" {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+
+		dsn += sep + params
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, err
 	}
@@ -25,19 +53,31 @@ func (m SQLiteDriver) Open(cfg Config) (Driver, error) {
 	db.SetMaxOpenConns(10)
 	db.SetMaxIdleConns(10)
 
-	d := SQLiteDriver{
+	d := &SQLiteDriver{
 		conn: db,
 		config: cfg,
+		owned: true,
 	}
 
 	return d, nil
 }
 
-func (m SQLiteDriver) Close() error {
-	return nil
+func (m *SQLiteDriver) Wrap(db *sql.DB, cfg Config) Driver {
+	return &SQLiteDriver{
+		conn: db,
+		config: cfg,
+	}
+}
+
+func (m *SQLiteDriver) Close() error {
+	if !m.owned {
+		return nil
+	}
+
+	return m.conn.Close()
 }
 
-func (m SQLiteDriver) Run(query string) error {
+func (m *SQLiteDriver) Run(query string) error {
 	_, err := m.conn.Exec(query)
 
 	if err != nil {
@@ -47,62 +87,264 @@ func (m SQLiteDriver) Run(query string) error {
 	return nil
 }
 
-func (m SQLiteDriver) Wipe() error {
-	query := `SELECT name FROM sqlite_master WHERE type = 'table';`
-	tables, err := m.conn.Query(query)
+// Objects are dropped in this order
+var sqliteDropOrder = []string{"trigger", "view", "index", "table"}
+
+func (m *SQLiteDriver) Wipe(opts WipeOptions) error {
+	ctx := context.Background()
+
+	// The foreign keys pragma is per connection so everything
+	// has to happen on the same one
+	conn, err := m.conn.Conn(ctx)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		if errClose := tables.Close(); errClose != nil {
-			fmt.Println("Err close ", errClose)
-		}
-	}()
 
-	tableNames := make([]string, 0)
-	for tables.Next() {
-		var tableName string
-		if err := tables.Scan(&tableName); err != nil {
-			return err
-		}
-		if len(tableName) > 0 {
-			tableNames = append(tableNames, tableName)
-		}
+	defer conn.Close()
+
+	schema := opts.Schema
+	if schema == "" {
+		schema = "main"
+	}
+
+	// Skip internal objects such as sqlite_sequence and automatic indexes
+	objects, err := queryObjects(ctx, conn, fmt.Sprintf(
+		"SELECT type, name, tbl_name FROM %s.sqlite_master WHERE name NOT LIKE 'sqlite_%%'",
+		quoteIdent(schema, `"`),
+	))
+	if err != nil {
+		return err
+	}
+
+	drop := opts.dropOrder(objects, sqliteDropOrder)
+
+	if len(drop) == 0 {
+		return nil
 	}
-	if err := tables.Err(); err != nil {
+
+	var fks bool
+	if err := conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&fks); err != nil {
 		return err
 	}
 
-	if len(tableNames) > 0 {
-		for _, t := range tableNames {
-			query := "DROP TABLE " + t
-			_, err = m.conn.Exec(query)
-			if err != nil {
-				return err
-			}
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+
+	errs := []error{}
+
+	for _, obj := range drop {
+		q := fmt.Sprintf("DROP %s IF EXISTS %s.%s", strings.ToUpper(obj.Kind), quoteIdent(schema, `"`), quoteIdent(obj.Name, `"`))
+
+		if _, err := conn.ExecContext(ctx, q); err != nil {
+			errs = append(errs, fmt.Errorf("failed dropping %s %s: %w", obj.Kind, obj.Name, err))
 		}
-		query := "VACUUM"
-		_, err = m.conn.Query(query)
-		if err != nil {
-			return err
+	}
+
+	if fks {
+		if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = ON"); err != nil {
+			errs = append(errs, err)
 		}
 	}
 
-	return nil
+	if _, err := conn.ExecContext(ctx, "VACUUM "+quoteIdent(schema, `"`)); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
 }
 
-func (m SQLiteDriver) CreateMigrationsTable() error {
-	_, err := m.conn.Exec(`
-		create table if not exists migrations (
+func (m *SQLiteDriver) CreateMigrationsTable(table string) error {
+	_, err := m.conn.Exec(fmt.Sprintf(`
+		create table if not exists %s (
 			id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
 			migration varchar(255),
 			batch int
 		)
-	`)
+	`, table))
 
 	return err
 }
 
-func (m SQLiteDriver) GetConnection() *sql.DB {
+func (m *SQLiteDriver) GetConnection() *sql.DB {
 	return m.conn
-}
\ No newline at end of file
+}
+
+func (m *SQLiteDriver) Inspect(schema string) (*Schema, error) {
+	if schema == "" {
+		schema = "main"
+	}
+
+	rows, err := m.conn.Query(fmt.Sprintf(
+		"SELECT name, sql FROM %s.sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%%'",
+		quoteIdent(schema, `"`),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Schema{}
+
+	for rows.Next() {
+		var t Table
+
+		if err := rows.Scan(&t.Name, &t.SQL); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		s.Tables = append(s.Tables, t)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for n := range s.Tables {
+		t := &s.Tables[n]
+		name := t.Name
+
+		rows, err := m.conn.Query(`SELECT name, type, "notnull", dflt_value FROM pragma_table_info(?, ?) ORDER BY cid`, name, schema)
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			var c Column
+			var notNull bool
+
+			if err := rows.Scan(&c.Name, &c.Type, &notNull, &c.Default); err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			c.Type = strings.ToLower(c.Type)
+			c.Nullable = !notNull
+			t.Columns = append(t.Columns, c)
+		}
+
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		// Primary keys are part of the table, not an index
+		rows, err = m.conn.Query(`SELECT name, "unique" FROM pragma_index_list(?, ?) WHERE origin != 'pk'`, name, schema)
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			var i Index
+
+			if err := rows.Scan(&i.Name, &i.Unique); err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			t.Indexes = append(t.Indexes, i)
+		}
+
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		for n := range t.Indexes {
+			// Expressions have no column name
+			rows, err := m.conn.Query("SELECT coalesce(name, '<expression>') FROM pragma_index_info(?, ?) ORDER BY seqno", t.Indexes[n].Name, schema)
+			if err != nil {
+				return nil, err
+			}
+
+			if t.Indexes[n].Columns, err = scanStrings(rows); err != nil {
+				return nil, err
+			}
+		}
+
+		// A missing "to" column means the primary key of the other table
+		rows, err = m.conn.Query(`SELECT id, "table", "from", coalesce("to", '') FROM pragma_foreign_key_list(?, ?) ORDER BY id, seq`, name, schema)
+		if err != nil {
+			return nil, err
+		}
+
+		last := -1
+
+		for rows.Next() {
+			var id int
+			var table, from, to string
+
+			if err := rows.Scan(&id, &table, &from, &to); err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			if id != last {
+				t.ForeignKeys = append(t.ForeignKeys, ForeignKey{RefTable: table})
+				last = id
+			}
+
+			fk := &t.ForeignKeys[len(t.ForeignKeys)-1]
+			fk.Columns = append(fk.Columns, from)
+			fk.RefColumns = append(fk.RefColumns, to)
+		}
+
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.sort()
+
+	return s, nil
+}
+
+// A temporary database file, deleted by the returned function
+func (m *SQLiteDriver) Scratch() (Driver, func() error, error) {
+	dir, err := os.MkdirTemp("", "migrate-scratch-")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	d, err := m.Open(Config{Database: filepath.Join(dir, "scratch.sqlite")})
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+
+	return d, func() error {
+		return errors.Join(d.Close(), os.RemoveAll(dir))
+	}, nil
+}
+
+// A copy of the database file in a temporary directory. VACUUM INTO
+// gives a consistent copy, even with a write ahead log.
+func (m *SQLiteDriver) Copy() (Driver, func() error, error) {
+	dir, err := os.MkdirTemp("", "migrate-copy-")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	path := filepath.Join(dir, "copy.sqlite")
+
+	if _, err := m.conn.Exec("VACUUM INTO ?", path); err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+
+	d, err := m.Open(Config{Database: path})
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+
+	return d, func() error {
+		return errors.Join(d.Close(), os.RemoveAll(dir))
+	}, nil
+}
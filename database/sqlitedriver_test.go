@@ -0,0 +1,215 @@
+package database
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSQLiteDSN(t *testing.T) {
+	d := t.TempDir()
+	db := filepath.Join(d, "testdb.sqlite")
+
+	driver, err := GetDriver(DriverSqlite, Config{
+		Database: "ignored.sqlite",
+		DSN:      "file:" + db + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+
+	var mode string
+	if err := driver.GetConnection().QueryRow("pragma journal_mode").Scan(&mode); err != nil {
+		t.Fatal(err)
+	}
+
+	if mode != "wal" {
+		t.Errorf("DSN pragmas were not applied, journal mode is %s", mode)
+	}
+
+	var timeout int
+	driver.GetConnection().QueryRow("pragma busy_timeout").Scan(&timeout)
+
+	if timeout != 5000 {
+		t.Errorf("DSN pragmas were not applied, busy timeout is %d", timeout)
+	}
+}
+
+func TestSQLiteParams(t *testing.T) {
+	d := t.TempDir()
+
+	driver, err := GetDriver(DriverSqlite, Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+		Params: map[string]string{
+			"_pragma": "busy_timeout(1234)",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+
+	var timeout int
+	driver.GetConnection().QueryRow("pragma busy_timeout").Scan(&timeout)
+
+	if timeout != 1234 {
+		t.Errorf("Params were not applied, busy timeout is %d", timeout)
+	}
+}
+
+func TestSQLiteWipe(t *testing.T) {
+	d := t.TempDir()
+
+	driver, err := GetDriver(DriverSqlite, Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+
+	err = driver.Run(`
+		PRAGMA foreign_keys = ON;
+		create table users (id integer primary key, email varchar(255));
+		create table posts (id integer primary key, user_id integer references users(id));
+		create index posts_user_id on posts (user_id);
+		create view user_posts as select * from users join posts on posts.user_id = users.id;
+		create trigger users_delete after delete on users begin delete from posts where user_id = old.id; end;
+		create table audit_log (id integer primary key autoincrement, event varchar(255));
+		create index audit_log_event on audit_log (event);
+		create trigger audit_users after insert on users begin insert into audit_log (event) values ('insert'); end;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := driver.Wipe(WipeOptions{Exclude: []string{"audit_*"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := driver.GetConnection().Query("select name from sqlite_master where name not like 'sqlite_%' order by name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	left := []string{}
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		left = append(left, name)
+	}
+
+	// audit_users matches the pattern but goes away with the users table
+	expected := []string{"audit_log", "audit_log_event"}
+
+	if !slices.Equal(left, expected) {
+		t.Errorf("Wipe left %v, expected %v", left, expected)
+	}
+}
+
+func TestSQLiteInspect(t *testing.T) {
+	driver, err := GetDriver(DriverSqlite, Config{Database: filepath.Join(t.TempDir(), "testdb.sqlite")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+
+	err = driver.Run(`
+		create table users (id integer primary key, email varchar(255) not null unique, name text default 'guest');
+		create table posts (id integer primary key, user_id int references users(id), title text);
+		create index posts_title on posts (title, user_id);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := driver.(Inspector).Inspect("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.Tables) != 2 || s.Tables[0].Name != "posts" {
+		t.Fatalf("Incorrect tables: %+v", s.Tables)
+	}
+
+	users := s.Table("users")
+
+	if email := users.Column("email"); email == nil || email.String() != "varchar(255) not null" {
+		t.Errorf("Incorrect email column: %+v", email)
+	}
+
+	if name := users.Column("name"); name == nil || name.String() != "text default 'guest'" {
+		t.Errorf("Incorrect name column: %+v", name)
+	}
+
+	if len(users.Indexes) != 1 || !users.Indexes[0].Unique {
+		t.Errorf("Expected the unique email index: %+v", users.Indexes)
+	}
+
+	posts := s.Table("posts")
+
+	if i := posts.Index("posts_title"); i == nil || !slices.Equal(i.Columns, []string{"title", "user_id"}) {
+		t.Errorf("Incorrect index: %+v", posts.Indexes)
+	}
+
+	if len(posts.ForeignKeys) != 1 || posts.ForeignKeys[0].String() != "(user_id) references users (id)" {
+		t.Errorf("Incorrect foreign keys: %+v", posts.ForeignKeys)
+	}
+}
+
+func TestSQLiteScratch(t *testing.T) {
+	driver, err := GetDriver(DriverSqlite, Config{Database: filepath.Join(t.TempDir(), "testdb.sqlite")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+
+	scratch, cleanup, err := driver.(Scratcher).Scratch()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := scratch.Run("create table scratch (id int)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, _ := driver.(Inspector).Inspect(""); len(s.Tables) != 0 {
+		t.Errorf("The scratch database should be separate: %+v", s.Tables)
+	}
+
+	if err := cleanup(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSQLiteCopy(t *testing.T) {
+	driver, err := GetDriver(DriverSqlite, Config{Database: filepath.Join(t.TempDir(), "testdb.sqlite")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Close()
+
+	driver.Run("create table users (id int); insert into users values (1);")
+
+	c, cleanup, err := driver.(Copier).Copy()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer cleanup()
+
+	var n int
+	c.GetConnection().QueryRow("select count(*) from users").Scan(&n)
+
+	if n != 1 {
+		t.Errorf("The copy should have the data, got %d rows", n)
+	}
+
+	c.Run("drop table users")
+
+	if s, _ := driver.(Inspector).Inspect(""); len(s.Tables) != 1 {
+		t.Errorf("Changes to the copy should not touch the database: %+v", s.Tables)
+	}
+}
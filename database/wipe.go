@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Limits what Wipe drops so a shared database doesn't lose
+// objects that belong to somebody else
+type WipeOptions struct {
+	// Objects matching any of these patterns are kept, along with the
+	// indexes and triggers of matching tables. Patterns use path.Match
+	// syntax, e.g. "audit_*".
+	Exclude []string
+	// Only drop objects in this schema. Defaults to the database we are
+	// connected to for MySQL and "main" for SQLite.
+	Schema string
+}
+
+// Something in the database Wipe can drop
+type dbObject struct {
+	Kind string // table, view, index, trigger, procedure, function or event
+	Name string
+	// Table the object belongs to, for indexes and triggers
+	Table string
+}
+
+func (o WipeOptions) excluded(name string) bool {
+	for _, pattern := range o.Exclude {
+		if ok, _ := path.Match(pattern, name); ok || pattern == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Whether Wipe should leave the object alone
+func (o WipeOptions) keep(obj dbObject) bool {
+	return o.excluded(obj.Name) || (obj.Table != "" && o.excluded(obj.Table))
+}
+
+// Filter out the objects we are keeping and sort the rest in the
+// order they need to be dropped in. Objects that depend on tables
+// go first so dropping the table doesn't take them with it.
+func (o WipeOptions) dropOrder(objects []dbObject, order []string) []dbObject {
+	drop := []dbObject{}
+
+	for _, obj := range objects {
+		if !o.keep(obj) {
+			drop = append(drop, obj)
+		}
+	}
+
+	slices.SortStableFunc(drop, func(a dbObject, b dbObject) int {
+		return slices.Index(order, a.Kind) - slices.Index(order, b.Kind)
+	})
+
+	return drop
+}
+
+// Run a query returning (kind, name, table) rows
+func queryObjects(ctx context.Context, conn *sql.Conn, query string, args ...any) ([]dbObject, error) {
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	objects := []dbObject{}
+
+	for rows.Next() {
+		var obj dbObject
+		var table sql.NullString
+
+		if err := rows.Scan(&obj.Kind, &obj.Name, &table); err != nil {
+			return nil, err
+		}
+
+		obj.Kind = strings.ToLower(obj.Kind)
+		obj.Table = table.String
+		objects = append(objects, obj)
+	}
+
+	return objects, rows.Err()
+}
+
+// Quote an identifier, escaping the quote character by doubling it
+func quoteIdent(name string, quote string) string {
+	return quote + strings.ReplaceAll(name, quote, quote+quote) + quote
+}
@@ -0,0 +1,211 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/javif89/migrate/database"
+)
+
+// How an object in the database differs from what the migrations produce
+type DiffChange string
+
+var DiffMissing DiffChange = "missing" // The migrations create it but the database doesn't have it
+var DiffExtra DiffChange = "extra"     // Only in the database, e.g. added by hand
+var DiffChanged DiffChange = "changed" // Defined differently in the database
+
+// A difference found by Diff
+type SchemaDifference struct {
+	Change DiffChange
+	// table, column, index or foreign key
+	Kind  string
+	Table string
+	// Column, index or foreign key. Empty for tables.
+	Name string
+	// Definition the migrations produce and the one in the database.
+	// Empty when there isn't one.
+	Expected string
+	Actual   string
+}
+
+func (d SchemaDifference) String() string {
+	name := d.Table
+	if d.Name != "" {
+		name += "." + d.Name
+	}
+
+	switch d.Change {
+	case DiffMissing:
+		return fmt.Sprintf("missing %s %s: %s", d.Kind, name, d.Expected)
+	case DiffExtra:
+		return fmt.Sprintf("extra %s %s: %s", d.Kind, name, d.Actual)
+	default:
+		return fmt.Sprintf("changed %s %s: expected %s, database has %s", d.Kind, name, d.Expected, d.Actual)
+	}
+}
+
+// Compare the schema the migrations produce with the database, to
+// catch changes made by hand. The migrations run on a scratch
+// database: a temporary SQLite file, or a temporary schema on the
+// same MySQL server. Pending migrations show up as missing objects.
+func (m *Migrations) Diff() ([]SchemaDifference, error) {
+	expected, err := m.expectedSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	actual, err := m.Schema()
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffSchemas(expected, actual, m.table), nil
+}
+
+// Tables, columns, indexes and foreign keys in the database
+func (m *Migrations) Schema() (*database.Schema, error) {
+	i, ok := m.driver.(database.Inspector)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s can't inspect the schema", database.ErrNotSupported, m.dialect)
+	}
+
+	return i.Inspect("")
+}
+
+// Schema the migrations produce on an empty database
+func (m *Migrations) expectedSchema() (*database.Schema, error) {
+	s, cleanup, err := m.scratch()
+	if err != nil {
+		return nil, err
+	}
+
+	defer cleanup()
+
+	if err := s.migrate(); err != nil && !errors.Is(err, ErrNoMigrations) && !errors.Is(err, ErrNoMigrationsToRun) {
+		return nil, fmt.Errorf("running the migrations on a scratch database: %w", err)
+	}
+
+	return s.Schema()
+}
+
+// A copy of m on a new, empty database, and a function that deletes it.
+// Every migration for the environment runs on it, whatever the tags.
+func (m *Migrations) scratch() (*Migrations, func() error, error) {
+	sc, ok := m.driver.(database.Scratcher)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s can't create a scratch database", database.ErrNotSupported, m.dialect)
+	}
+
+	d, cleanup, err := sc.Scratch()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s := m.on(d)
+	s.tags = nil
+	s.OutOfOrder = OutOfOrderAllow
+
+	return s, cleanup, nil
+}
+
+// A copy of m running on another database, without callbacks, locks or logs
+func (m *Migrations) on(d database.Driver) *Migrations {
+	s := *m
+	s.driver = d
+	s.lock = nil
+	s.Hooks = Hooks{}
+	s.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	return &s
+}
+
+// Differences between two schemas, by table. Tables in ignore are
+// left out, like the migrations table, which no migration creates.
+func DiffSchemas(expected *database.Schema, actual *database.Schema, ignore ...string) []SchemaDifference {
+	diffs := []SchemaDifference{}
+
+	for _, e := range expected.Tables {
+		if slices.Contains(ignore, e.Name) {
+			continue
+		}
+
+		a := actual.Table(e.Name)
+		if a == nil {
+			diffs = append(diffs, SchemaDifference{Change: DiffMissing, Kind: "table", Table: e.Name, Expected: tableSummary(e)})
+			continue
+		}
+
+		diffs = append(diffs, diffTables(e, *a)...)
+	}
+
+	for _, a := range actual.Tables {
+		if !slices.Contains(ignore, a.Name) && expected.Table(a.Name) == nil {
+			diffs = append(diffs, SchemaDifference{Change: DiffExtra, Kind: "table", Table: a.Name, Actual: tableSummary(a)})
+		}
+	}
+
+	return diffs
+}
+
+func diffTables(e database.Table, a database.Table) []SchemaDifference {
+	diffs := []SchemaDifference{}
+	add := func(change DiffChange, kind string, name string, expected string, actual string) {
+		diffs = append(diffs, SchemaDifference{Change: change, Kind: kind, Table: e.Name, Name: name, Expected: expected, Actual: actual})
+	}
+
+	for _, c := range e.Columns {
+		if ac := a.Column(c.Name); ac == nil {
+			add(DiffMissing, "column", c.Name, c.String(), "")
+		} else if ac.String() != c.String() {
+			add(DiffChanged, "column", c.Name, c.String(), ac.String())
+		}
+	}
+
+	for _, c := range a.Columns {
+		if e.Column(c.Name) == nil {
+			add(DiffExtra, "column", c.Name, "", c.String())
+		}
+	}
+
+	for _, i := range e.Indexes {
+		if ai := a.Index(i.Name); ai == nil {
+			add(DiffMissing, "index", i.Name, i.String(), "")
+		} else if ai.String() != i.String() {
+			add(DiffChanged, "index", i.Name, i.String(), ai.String())
+		}
+	}
+
+	for _, i := range a.Indexes {
+		if e.Index(i.Name) == nil {
+			add(DiffExtra, "index", i.Name, "", i.String())
+		}
+	}
+
+	for _, fk := range e.ForeignKeys {
+		if a.ForeignKey(fk) == nil {
+			add(DiffMissing, "foreign key", fk.Name, fk.String(), "")
+		}
+	}
+
+	for _, fk := range a.ForeignKeys {
+		if e.ForeignKey(fk) == nil {
+			add(DiffExtra, "foreign key", fk.Name, "", fk.String())
+		}
+	}
+
+	return diffs
+}
+
+// Column names of a table, e.g. (id, email)
+func tableSummary(t database.Table) string {
+	columns := []string{}
+	for _, c := range t.Columns {
+		columns = append(columns, c.Name)
+	}
+
+	return "(" + strings.Join(columns, ", ") + ")"
+}
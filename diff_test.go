@@ -0,0 +1,72 @@
+package migrate
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javif89/migrate/database"
+)
+
+func TestDiff(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	writeMigration(t, mgf, "2024_01_01_000000_create_users_table", "-- UP --\ncreate table users (id integer primary key, email varchar(255) not null);\ncreate unique index users_email on users (email);\n-- DOWN --\ndrop table users;")
+	writeMigration(t, mgf, "2024_01_02_000000_create_posts_table", "-- UP --\ncreate table posts (id integer primary key, user_id int references users(id));\n-- DOWN --\ndrop table posts;")
+
+	if err := m.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	diffs, err := m.Diff()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diffs) != 0 {
+		t.Fatalf("Expected no differences after migrating, got %v", diffs)
+	}
+
+	// Hotfixes applied by hand
+	err = m.driver.Run(`
+		alter table users add column nickname text;
+		drop index users_email;
+		create table audit (id int);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeMigration(t, mgf, "2024_01_03_000000_create_tags_table", "-- UP --\ncreate table tags (id int);\n-- DOWN --\ndrop table tags;")
+
+	diffs, err = m.Diff()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := []string{}
+	for _, d := range diffs {
+		found = append(found, d.String())
+	}
+
+	expected := []string{
+		"missing table tags: (id)",
+		"extra column users.nickname: text",
+		"missing index users.users_email: unique (email)",
+		"extra table audit: (id)",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(strings.Join(found, "\n"), e) {
+			t.Errorf("Expected %q in:\n%s", e, strings.Join(found, "\n"))
+		}
+	}
+
+	if len(diffs) != len(expected) {
+		t.Errorf("Expected %d differences, got %v", len(expected), found)
+	}
+}
@@ -0,0 +1,162 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrInvalidDirective error = errors.New("invalid directive")
+var ErrUnmetRequirement error = errors.New("unmet requirement")
+
+// Directives start with this prefix, e.g.
+//
+//	-- migrate:no-transaction
+var directivePrefix = "-- migrate:"
+
+// Per migration settings, declared in the file with comments like
+//
+//	-- migrate:no-transaction
+//	-- migrate:timeout 30s
+//	-- migrate:env staging,prod
+//	-- migrate:requires 2024_01_02_150405_create_users_table
+//	-- migrate:tag data
+//	-- migrate:lint-ignore drop-column
+type Directives struct {
+	// Don't wrap the migration in a transaction, e.g. for
+	// statements that can't run inside one
+	NoTransaction bool
+	// Cancel the migration if it takes longer than this
+	Timeout time.Duration
+	// Only run in these environments
+	Envs []string
+	// Migrations that have to be applied before this one
+	Requires []string
+	Tags     []string
+	// Lint rules that don't apply to this migration
+	LintIgnore []string
+}
+
+// Directives declared in the migration file
+func (m *Migration) Directives() (Directives, error) {
+	c, err := m.GetContent()
+
+	if err != nil {
+		return Directives{}, err
+	}
+
+	d, err := parseDirectives(c)
+	if err != nil {
+		return d, fmt.Errorf("%s: %w", m.Name(), err)
+	}
+
+	return d, nil
+}
+
+func parseDirectives(content string) (Directives, error) {
+	d := Directives{}
+
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+
+		if !strings.HasPrefix(line, directivePrefix) {
+			continue
+		}
+
+		name, value, _ := strings.Cut(strings.TrimPrefix(line, directivePrefix), " ")
+		value = strings.TrimSpace(value)
+
+		switch name {
+		case "no-transaction":
+			d.NoTransaction = true
+		case "timeout":
+			timeout, err := time.ParseDuration(value)
+			if err != nil || timeout <= 0 {
+				return d, fmt.Errorf("%w on line %d: timeout must be a duration like 30s, got %q", ErrInvalidDirective, i+1, value)
+			}
+
+			d.Timeout = timeout
+		case "env":
+			d.Envs = append(d.Envs, splitList(value)...)
+		case "requires":
+			d.Requires = append(d.Requires, splitList(value)...)
+		case "tag":
+			d.Tags = append(d.Tags, splitList(value)...)
+		case "lint-ignore":
+			d.LintIgnore = append(d.LintIgnore, splitList(value)...)
+		default:
+			return d, fmt.Errorf("%w on line %d: unknown directive %q", ErrInvalidDirective, i+1, name)
+		}
+	}
+
+	return d, nil
+}
+
+// Split a comma separated directive value
+func splitList(value string) []string {
+	values := []string{}
+
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	return values
+}
+
+// Whether the migration should run in the current environment
+// and with the tags we were asked to run
+func (m *Migrations) selects(d Directives) bool {
+	if !m.inEnvironment(d) {
+		return false
+	}
+
+	if len(m.tags) > 0 && !slices.ContainsFunc(d.Tags, func(tag string) bool {
+		return slices.Contains(m.tags, tag)
+	}) {
+		return false
+	}
+
+	return true
+}
+
+// Migrations without -- migrate:env run everywhere
+func (m *Migrations) inEnvironment(d Directives) bool {
+	if len(d.Envs) == 0 {
+		return true
+	}
+
+	return slices.ContainsFunc(d.Envs, func(env string) bool {
+		return m.environment != "" && strings.EqualFold(env, m.environment)
+	})
+}
+
+// Human readable summary of the directives, used in plans
+func (d Directives) String() string {
+	parts := []string{}
+
+	if d.NoTransaction {
+		parts = append(parts, "no transaction")
+	}
+
+	if d.Timeout > 0 {
+		parts = append(parts, "timeout "+d.Timeout.String())
+	}
+
+	if len(d.Envs) > 0 {
+		parts = append(parts, "env "+strings.Join(d.Envs, ","))
+	}
+
+	if len(d.Requires) > 0 {
+		parts = append(parts, "requires "+strings.Join(d.Requires, ","))
+	}
+
+	if len(d.Tags) > 0 {
+		parts = append(parts, "tags "+strings.Join(d.Tags, ","))
+	}
+
+	return strings.Join(parts, ", ")
+}
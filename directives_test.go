@@ -0,0 +1,172 @@
+package migrate
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/javif89/migrate/database"
+)
+
+func TestParseDirectives(t *testing.T) {
+	d, err := parseDirectives(`-- migrate:no-transaction
+-- migrate:timeout 30s
+-- migrate:env staging, prod
+-- migrate:requires 2024_01_01_000000_users
+-- migrate:tag data
+-- migrate:tag backfill
+-- UP --
+select 1;
+-- DOWN --
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !d.NoTransaction || d.Timeout != 30*time.Second {
+		t.Errorf("Incorrect directives: %+v", d)
+	}
+
+	if !slices.Equal(d.Envs, []string{"staging", "prod"}) || !slices.Equal(d.Tags, []string{"data", "backfill"}) {
+		t.Errorf("Incorrect lists: %+v", d)
+	}
+
+	if !slices.Equal(d.Requires, []string{"2024_01_01_000000_users"}) {
+		t.Errorf("Incorrect requirements: %v", d.Requires)
+	}
+
+	if _, err := parseDirectives("-- migrate:no-transactions"); !errors.Is(err, ErrInvalidDirective) {
+		t.Errorf("Expected an error for an unknown directive, got %v", err)
+	}
+
+	if _, err := parseDirectives("-- migrate:timeout soon"); !errors.Is(err, ErrInvalidDirective) {
+		t.Errorf("Expected an error for an invalid timeout, got %v", err)
+	}
+}
+
+func TestMigrationsRunInTransactions(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	conn := m.driver.GetConnection()
+	count := func(table string) int {
+		var n int
+		conn.QueryRow("select count(*) from sqlite_master where name = ?", table).Scan(&n)
+		return n
+	}
+
+	writeMigration(t, mgf, "2024_01_01_000000_atomic", "-- UP --\ncreate table atomic (id int);\ncreate table broken (;\n-- DOWN --\n")
+
+	if err := m.Migrate(); err == nil {
+		t.Fatal("Expected the migration to fail")
+	}
+
+	if count("atomic") != 0 {
+		t.Errorf("The failed migration should be rolled back")
+	}
+
+	writeMigration(t, mgf, "2024_01_01_000000_atomic", "-- migrate:no-transaction\n-- UP --\ncreate table atomic (id int);\ncreate table broken (;\n-- DOWN --\n")
+
+	if err := m.Migrate(); err == nil {
+		t.Fatal("Expected the migration to fail")
+	}
+
+	if count("atomic") != 1 {
+		t.Errorf("Without a transaction the first statement should stay applied")
+	}
+}
+
+func TestMigrationTimeout(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	writeMigration(t, mgf, "2024_01_01_000000_slow", `-- migrate:timeout 50ms
+-- UP --
+with recursive n(i) as (select 1 union all select i + 1 from n) select count(*) from n;
+-- DOWN --
+`)
+
+	if err := m.Migrate(); err == nil {
+		t.Fatalf("Expected the migration to time out, got %v", err)
+	}
+
+	if len(m.GetExistingMigrations()) != 0 {
+		t.Errorf("A timed out migration should not be recorded")
+	}
+}
+
+func TestMigrationEnvAndTags(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	db := filepath.Join(d, "testdb.sqlite")
+
+	writeMigration(t, mgf, "2024_01_01_000000_users", "-- UP --\ncreate table users (id int);\n-- DOWN --\ndrop table users;")
+	writeMigration(t, mgf, "2024_01_02_000000_fixtures", "-- migrate:env local\n-- UP --\ninsert into users values (1);\n-- DOWN --\ndelete from users;")
+	writeMigration(t, mgf, "2024_01_03_000000_backfill", "-- migrate:tag data\n-- UP --\ninsert into users values (2);\n-- DOWN --\ndelete from users where id = 2;")
+
+	m := New(mgf, database.DriverSqlite, database.Config{Database: db}, WithEnvironment("production"), WithTags("data"))
+
+	p, err := m.Plan(Up, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Steps) != 1 || p.Steps[0].Directives.Tags[0] != "data" {
+		t.Fatalf("Only the tagged migration should be planned: %+v", p.Steps)
+	}
+
+	m = New(mgf, database.DriverSqlite, database.Config{Database: db}, WithEnvironment("production"))
+	m.Migrate()
+
+	existing := m.GetExistingMigrations()
+
+	if len(existing) != 2 || slices.ContainsFunc(existing, func(n string) bool { return n == "2024_01_02_000000_fixture" }) {
+		t.Errorf("Migrations for other environments should be skipped: %v", existing)
+	}
+}
+
+func TestMigrationRequires(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	writeMigration(t, mgf, "2024_01_01_000000_users", "-- migrate:tag schema\n-- UP --\ncreate table users (id int);\n-- DOWN --\ndrop table users;")
+	writeMigration(t, mgf, "2024_01_02_000000_posts", "-- migrate:requires 2024_01_01_000000_users\n-- UP --\ncreate table posts (id int);\n-- DOWN --\ndrop table posts;")
+
+	// The requirement runs earlier in the same plan
+	if err := m.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rolling back users would break posts, which stays applied
+	m.tags = []string{"schema"}
+
+	if err := m.Rollback(); !errors.Is(err, ErrUnmetRequirement) {
+		t.Errorf("Expected an unmet requirement error on rollback, got %v", err)
+	}
+
+	m.tags = nil
+
+	writeMigration(t, mgf, "2024_01_03_000000_tags", "-- migrate:requires 2024_01_09_000000_unknown\n-- UP --\nselect 1;\n-- DOWN --\n")
+
+	if err := m.Migrate(); !errors.Is(err, ErrUnmetRequirement) {
+		t.Errorf("Expected an unmet requirement error, got %v", err)
+	}
+
+	// Requirements applied in an earlier batch count too
+	writeMigration(t, mgf, "2024_01_03_000000_tags", "-- migrate:requires 2024_01_01_000000_users\n-- UP --\nselect 1;\n-- DOWN --\n")
+
+	if err := m.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+}
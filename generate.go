@@ -0,0 +1,275 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/javif89/migrate/database"
+)
+
+var ErrNoSchemaChanges error = errors.New("the database already matches the schema")
+
+// A statement that brings the database closer to a desired
+// schema, and the one that undoes it
+type SchemaChange struct {
+	Difference SchemaDifference
+	Up         string
+	Down       string
+	// Loses or converts data, e.g. drops a table or a column.
+	// It should be reviewed before running.
+	Destructive bool
+}
+
+// Statements that turn the database into the schema created by the SQL
+// in the desired file. The file runs on a scratch database, like Diff.
+// Changes SQLite can't make with ALTER TABLE are left as TODO comments.
+func (m *Migrations) SchemaChanges(desired string) ([]SchemaChange, error) {
+	expected, err := m.schemaFromFile(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	actual, err := m.Schema()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []SchemaChange{}
+
+	for _, d := range DiffSchemas(expected, actual, m.table) {
+		changes = append(changes, m.schemaChange(d, expected, actual))
+	}
+
+	// Create tables before anything points at them, and drop
+	// foreign keys and indexes before what they point at
+	slices.SortStableFunc(changes, func(a SchemaChange, b SchemaChange) int {
+		return changeOrder(a.Difference) - changeOrder(b.Difference)
+	})
+
+	return changes, nil
+}
+
+// Schema the SQL in the file produces on an empty database
+func (m *Migrations) schemaFromFile(path string) (*database.Schema, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s, cleanup, err := m.scratch()
+	if err != nil {
+		return nil, err
+	}
+
+	defer cleanup()
+
+	if err := s.driver.Run(string(content)); err != nil {
+		return nil, fmt.Errorf("running %s on a scratch database: %w", path, err)
+	}
+
+	return s.Schema()
+}
+
+// Write a migration with the changes to the desired schema
+func (m *Migrations) migrationFromDiff(desired string) (string, error) {
+	changes, err := m.SchemaChanges(desired)
+	if err != nil {
+		return "", err
+	}
+
+	if len(changes) == 0 {
+		return "", ErrNoSchemaChanges
+	}
+
+	up := []string{}
+	down := []string{}
+
+	for _, c := range changes {
+		if c.Destructive {
+			m.logger().Warn("destructive change, review it before running", "change", c.Difference.String())
+			up = append(up, "-- DESTRUCTIVE, review before running: "+c.Difference.String())
+		}
+
+		up = append(up, c.Up)
+		down = append(down, c.Down)
+	}
+
+	slices.Reverse(down)
+
+	return "-- UP --\n" + strings.Join(up, "\n") + "\n\n-- DOWN --\n" + strings.Join(down, "\n"), nil
+}
+
+// Position of a change in the migration
+func changeOrder(d SchemaDifference) int {
+	switch {
+	case d.Kind == "table" && d.Change == DiffMissing:
+		return 0
+	case d.Change == DiffExtra && (d.Kind == "foreign key" || d.Kind == "index"):
+		return 1
+	case d.Kind == "column" && d.Change != DiffExtra:
+		return 2
+	case d.Kind == "index" || d.Kind == "foreign key":
+		return 3
+	case d.Kind == "column":
+		return 4
+	}
+
+	return 5
+}
+
+func (m *Migrations) schemaChange(d SchemaDifference, expected *database.Schema, actual *database.Schema) SchemaChange {
+	c := SchemaChange{Difference: d}
+	e := expected.Table(d.Table)
+	a := actual.Table(d.Table)
+	table := m.quote(d.Table)
+
+	switch d.Kind {
+	case "table":
+		if d.Change == DiffMissing {
+			c.Up = m.createTable(*e)
+			c.Down = fmt.Sprintf("DROP TABLE %s;", table)
+		} else {
+			c.Up = fmt.Sprintf("DROP TABLE %s;", table)
+			c.Down = m.createTable(*a)
+			c.Destructive = true
+		}
+	case "column":
+		switch d.Change {
+		case DiffMissing:
+			c.Up = fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", table, m.columnDefinition(*e.Column(d.Name)))
+			c.Down = fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", table, m.quote(d.Name))
+		case DiffExtra:
+			c.Up = fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", table, m.quote(d.Name))
+			c.Down = fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", table, m.columnDefinition(*a.Column(d.Name)))
+			c.Destructive = true
+		default:
+			if m.dialect != database.DriverMysql {
+				return m.manualChange(c, "change column")
+			}
+
+			c.Up = fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s;", table, m.columnDefinition(*e.Column(d.Name)))
+			c.Down = fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s;", table, m.columnDefinition(*a.Column(d.Name)))
+			c.Destructive = true
+		}
+	case "index":
+		// SQLite creates these for UNIQUE constraints
+		if strings.HasPrefix(d.Name, "sqlite_autoindex_") {
+			return m.manualChange(c, "change a unique constraint")
+		}
+
+		switch d.Change {
+		case DiffMissing:
+			c.Up = m.createIndex(d.Table, *e.Index(d.Name))
+			c.Down = m.dropIndex(d.Table, d.Name)
+		case DiffExtra:
+			c.Up = m.dropIndex(d.Table, d.Name)
+			c.Down = m.createIndex(d.Table, *a.Index(d.Name))
+		default:
+			c.Up = m.dropIndex(d.Table, d.Name) + "\n" + m.createIndex(d.Table, *e.Index(d.Name))
+			c.Down = m.dropIndex(d.Table, d.Name) + "\n" + m.createIndex(d.Table, *a.Index(d.Name))
+		}
+	case "foreign key":
+		if m.dialect != database.DriverMysql {
+			return m.manualChange(c, "change a foreign key")
+		}
+
+		if d.Change == DiffMissing {
+			fk := findForeignKey(e, d.Expected)
+			c.Up = m.addForeignKey(d.Table, fk)
+			c.Down = fmt.Sprintf("ALTER TABLE %s DROP FOREIGN KEY %s;", table, m.quote(fk.Name))
+		} else {
+			fk := findForeignKey(a, d.Actual)
+			c.Up = fmt.Sprintf("ALTER TABLE %s DROP FOREIGN KEY %s;", table, m.quote(fk.Name))
+			c.Down = m.addForeignKey(d.Table, fk)
+		}
+	}
+
+	return c
+}
+
+// For changes ALTER TABLE can't make on the driver
+func (m *Migrations) manualChange(c SchemaChange, what string) SchemaChange {
+	c.Up = fmt.Sprintf("-- TODO: %s can't %s with ALTER TABLE, rebuild %s: %s", m.dialect, what, c.Difference.Table, c.Difference)
+	c.Down = fmt.Sprintf("-- TODO: undo %s", c.Difference)
+
+	return c
+}
+
+// The table as the database reports it. MySQL includes the
+// indexes, SQLite keeps them as separate statements.
+func (m *Migrations) createTable(t database.Table) string {
+	statements := []string{t.SQL + ";"}
+
+	if m.dialect != database.DriverMysql {
+		for _, i := range t.Indexes {
+			if !strings.HasPrefix(i.Name, "sqlite_autoindex_") {
+				statements = append(statements, m.createIndex(t.Name, i))
+			}
+		}
+	}
+
+	return strings.Join(statements, "\n")
+}
+
+func (m *Migrations) columnDefinition(c database.Column) string {
+	return m.quote(c.Name) + " " + c.String()
+}
+
+func (m *Migrations) createIndex(table string, i database.Index) string {
+	unique := ""
+	if i.Unique {
+		unique = "UNIQUE "
+	}
+
+	return fmt.Sprintf("CREATE %sINDEX %s ON %s (%s);", unique, m.quote(i.Name), m.quote(table), m.quoteList(i.Columns))
+}
+
+func (m *Migrations) dropIndex(table string, name string) string {
+	if m.dialect == database.DriverMysql {
+		return fmt.Sprintf("DROP INDEX %s ON %s;", m.quote(name), m.quote(table))
+	}
+
+	return fmt.Sprintf("DROP INDEX %s;", m.quote(name))
+}
+
+func (m *Migrations) addForeignKey(table string, fk database.ForeignKey) string {
+	constraint := ""
+	if fk.Name != "" {
+		constraint = "CONSTRAINT " + m.quote(fk.Name) + " "
+	}
+
+	return fmt.Sprintf("ALTER TABLE %s ADD %sFOREIGN KEY (%s) REFERENCES %s (%s);",
+		m.quote(table), constraint, m.quoteList(fk.Columns), m.quote(fk.RefTable), m.quoteList(fk.RefColumns))
+}
+
+func findForeignKey(t *database.Table, definition string) database.ForeignKey {
+	for _, fk := range t.ForeignKeys {
+		if fk.String() == definition {
+			return fk
+		}
+	}
+
+	return database.ForeignKey{}
+}
+
+// Quote an identifier for the driver
+func (m *Migrations) quote(name string) string {
+	q := `"`
+	if m.dialect == database.DriverMysql {
+		q = "`"
+	}
+
+	return q + strings.ReplaceAll(name, q, q+q) + q
+}
+
+func (m *Migrations) quoteList(names []string) string {
+	quoted := []string{}
+	for _, n := range names {
+		quoted = append(quoted, m.quote(n))
+	}
+
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,80 @@
+package migrate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javif89/migrate/database"
+)
+
+func TestCreateMigrationFromDiff(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	writeMigration(t, mgf, "2024_01_01_000000_create_users_table", "-- UP --\ncreate table users (id integer primary key, email text not null, nickname text);\n-- DOWN --\ndrop table users;")
+
+	if err := m.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	desired := filepath.Join(d, "schema.sql")
+	os.WriteFile(desired, []byte(`
+		create table users (id integer primary key, email text not null, name text not null default 'guest');
+		create unique index users_email on users (email);
+		create table posts (id integer primary key, user_id int references users(id));
+		create index posts_user on posts (user_id);
+	`), 0644)
+
+	path, err := m.CreateMigrationFrom("update_schema", CreateOptions{FromDiff: desired})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := os.ReadFile(path)
+
+	for _, s := range []string{
+		`CREATE TABLE posts (id integer primary key, user_id int references users(id));`,
+		`CREATE INDEX "posts_user" ON "posts" ("user_id");`,
+		`ALTER TABLE "users" ADD COLUMN "name" text not null default 'guest';`,
+		`CREATE UNIQUE INDEX "users_email" ON "users" ("email");`,
+		"-- DESTRUCTIVE, review before running: extra column users.nickname: text\nALTER TABLE \"users\" DROP COLUMN \"nickname\";",
+		`DROP TABLE "posts";`,
+		`ALTER TABLE "users" ADD COLUMN "nickname" text;`,
+	} {
+		if !strings.Contains(string(content), s) {
+			t.Errorf("Expected %q in the migration:\n%s", s, content)
+		}
+	}
+
+	if strings.Index(string(content), `DROP TABLE "posts"`) < strings.Index(string(content), "-- DOWN --") {
+		t.Errorf("Dropping the new table belongs in the down section:\n%s", content)
+	}
+
+	if err := m.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if changes, err := m.SchemaChanges(desired); err != nil || len(changes) != 0 {
+		t.Errorf("The database should match the desired schema, got %v %v", changes, err)
+	}
+
+	if _, err := m.CreateMigrationFrom("update_schema", CreateOptions{FromDiff: desired}); !errors.Is(err, ErrNoSchemaChanges) {
+		t.Errorf("Expected ErrNoSchemaChanges, got %v", err)
+	}
+
+	if err := m.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, _ := m.Schema()
+
+	if s.Table("posts") != nil || s.Table("users").Column("nickname") == nil || s.Table("users").Column("name") != nil {
+		t.Errorf("Rolling back should restore the schema, got %+v", s.Tables)
+	}
+}
@@ -7,6 +7,7 @@ require (
 	github.com/javif89/dotenv v0.0.6
 	github.com/ncruces/go-sqlite3 v0.16.0
 	github.com/urfave/cli/v2 v2.27.2
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
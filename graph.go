@@ -0,0 +1,198 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var ErrDependencyCycle error = errors.New("dependency cycle")
+var ErrDuplicateMigration error = errors.New("duplicate migration")
+
+// Returned when migrations require each other, directly or not
+type DependencyCycleError struct {
+	// Migrations in the cycle, starting and ending with the same one
+	Cycle []string
+}
+
+func (e *DependencyCycleError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrDependencyCycle, strings.Join(e.Cycle, " -> "))
+}
+
+func (e *DependencyCycleError) Unwrap() error {
+	return ErrDependencyCycle
+}
+
+// Output formats for WriteGraph
+type GraphFormat string
+
+var GraphText GraphFormat = "text"
+var GraphDot GraphFormat = "dot" // Graphviz
+
+// Order migrations so each one runs after the migrations it requires.
+// Otherwise they keep their order, which is by name. Requirements that
+// don't match any of the migrations are left for the plan to check.
+func sortByDependencies(migrations []Migration) ([]Migration, error) {
+	deps, err := dependencies(migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	// How many unsorted requirements each migration is waiting on
+	waiting := make([]int, len(migrations))
+	dependents := make([][]int, len(migrations))
+
+	for i, reqs := range deps {
+		waiting[i] = len(reqs)
+
+		for _, r := range reqs {
+			dependents[r] = append(dependents[r], i)
+		}
+	}
+
+	sorted := []Migration{}
+	done := make([]bool, len(migrations))
+
+	// Always take the first migration that is ready, so the
+	// order only changes where a requirement forces it to
+	for len(sorted) < len(migrations) {
+		next := -1
+
+		for i := range migrations {
+			if !done[i] && waiting[i] == 0 {
+				next = i
+				break
+			}
+		}
+
+		if next == -1 {
+			return nil, &DependencyCycleError{Cycle: findCycle(migrations, deps, done)}
+		}
+
+		done[next] = true
+		sorted = append(sorted, migrations[next])
+
+		for _, d := range dependents[next] {
+			waiting[d]--
+		}
+	}
+
+	return sorted, nil
+}
+
+// Requirements of each migration, as indexes into migrations
+func dependencies(migrations []Migration) ([][]int, error) {
+	deps := make([][]int, len(migrations))
+
+	for i, mg := range migrations {
+		d, err := mg.Directives()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, req := range d.Requires {
+			if r := indexOf(migrations, recordName(req)); r != -1 && !slices.Contains(deps[i], r) {
+				deps[i] = append(deps[i], r)
+			}
+		}
+	}
+
+	return deps, nil
+}
+
+// Follow requirements between unsorted migrations until one repeats.
+// Every unsorted migration is waiting on another, so there is one.
+func findCycle(migrations []Migration, deps [][]int, done []bool) []string {
+	start := slices.Index(done, false)
+	seen := map[int]int{}
+	path := []int{}
+
+	for i := start; ; {
+		if at, ok := seen[i]; ok {
+			path = append(path[at:], i)
+			break
+		}
+
+		seen[i] = len(path)
+		path = append(path, i)
+
+		i = deps[i][slices.IndexFunc(deps[i], func(r int) bool { return !done[r] })]
+	}
+
+	names := []string{}
+	for _, i := range path {
+		names = append(names, migrations[i].Name())
+	}
+
+	return names
+}
+
+// Write the dependency graph of the migrations, in the order they run.
+// In DOT, edges point from a migration to the ones that require it and
+// migrations are grouped by folder when there are module paths.
+func (m *Migrations) WriteGraph(w io.Writer, format GraphFormat) error {
+	migrations, err := m.allMigrations()
+	if err != nil {
+		return err
+	}
+
+	deps, err := dependencies(migrations)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case GraphText:
+		for i, mg := range migrations {
+			fmt.Fprintln(w, mg.Name())
+
+			for _, r := range deps[i] {
+				fmt.Fprintf(w, "  requires %s\n", migrations[r].Name())
+			}
+		}
+	case GraphDot:
+		fmt.Fprintln(w, "digraph migrations {")
+		fmt.Fprintln(w, "  rankdir=LR;")
+
+		folders := []string{}
+		for _, mg := range migrations {
+			if dir := filepath.Dir(mg.Path); !slices.Contains(folders, dir) {
+				folders = append(folders, dir)
+			}
+		}
+
+		for i, dir := range folders {
+			indent := "  "
+
+			if len(folders) > 1 {
+				fmt.Fprintf(w, "  subgraph cluster_%d {\n    label=%q;\n", i, dir)
+				indent = "    "
+			}
+
+			for _, mg := range migrations {
+				if filepath.Dir(mg.Path) == dir {
+					fmt.Fprintf(w, "%s%q;\n", indent, mg.Name())
+				}
+			}
+
+			if len(folders) > 1 {
+				fmt.Fprintln(w, "  }")
+			}
+		}
+
+		for i, mg := range migrations {
+			for _, r := range deps[i] {
+				fmt.Fprintf(w, "  %q -> %q;\n", migrations[r].Name(), mg.Name())
+			}
+		}
+
+		fmt.Fprintln(w, "}")
+	default:
+		return fmt.Errorf("unknown graph format %q. Use text or dot", format)
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package migrate
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javif89/migrate/database"
+)
+
+func TestDependencyOrder(t *testing.T) {
+	d := t.TempDir()
+	core := filepath.Join(d, "migrations")
+	billing := filepath.Join(d, "modules", "billing")
+
+	m := New(core, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	}, WithModulePaths(billing))
+
+	// The invoices migration is older but needs the users table
+	writeMigration(t, billing, "2024_01_01_000000_create_invoices_table", "-- migrate:requires 2024_01_02_000000_create_users_table\n-- UP --\ncreate table invoices (user_id int references users(id));\n-- DOWN --\ndrop table invoices;")
+	writeMigration(t, core, "2024_01_02_000000_create_users_table", "-- UP --\ncreate table users (id int primary key);\n-- DOWN --\ndrop table users;")
+	writeMigration(t, core, "2024_01_03_000000_create_posts_table", "-- UP --\ncreate table posts (id int);\n-- DOWN --\ndrop table posts;")
+
+	migrations, err := m.GetMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, mg := range migrations {
+		names = append(names, mg.Name())
+	}
+
+	expected := "2024_01_02_000000_create_users_table,2024_01_01_000000_create_invoices_table,2024_01_03_000000_create_posts_table"
+
+	if strings.Join(names, ",") != expected {
+		t.Errorf("Incorrect order: %v", names)
+	}
+
+	if err := m.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := m.WriteGraph(&buf, GraphDot); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), `"2024_01_02_000000_create_users_table" -> "2024_01_01_000000_create_invoices_table";`) {
+		t.Errorf("Missing edge in graph:\n%s", buf.String())
+	}
+
+	if strings.Count(buf.String(), "subgraph") != 2 {
+		t.Errorf("Expected one cluster per folder:\n%s", buf.String())
+	}
+
+	// Rollbacks run in reverse, so invoices goes before users
+	rolledBack := []string{}
+	m.Hooks.AfterMigration = func(e Event) {
+		rolledBack = append(rolledBack, e.Migration.Name())
+	}
+
+	if err := m.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(rolledBack, ",") != "2024_01_03_000000_create_posts_table,2024_01_01_000000_create_invoices_table,2024_01_02_000000_create_users_table" {
+		t.Errorf("Incorrect rollback order: %v", rolledBack)
+	}
+}
+
+func TestDependencyCycle(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	writeMigration(t, mgf, "2024_01_01_000000_a", "-- migrate:requires 2024_01_03_000000_c\n-- UP --\n-- DOWN --\n")
+	writeMigration(t, mgf, "2024_01_02_000000_b", "-- migrate:requires 2024_01_01_000000_a\n-- UP --\n-- DOWN --\n")
+	writeMigration(t, mgf, "2024_01_03_000000_c", "-- migrate:requires 2024_01_02_000000_b\n-- UP --\n-- DOWN --\n")
+	writeMigration(t, mgf, "2024_01_04_000000_d", "-- UP --\n-- DOWN --\n")
+
+	_, err := m.GetMigrations()
+
+	var cycleErr *DependencyCycleError
+	if !errors.As(err, &cycleErr) || !errors.Is(err, ErrDependencyCycle) {
+		t.Fatalf("Expected a dependency cycle error, got %v", err)
+	}
+
+	if len(cycleErr.Cycle) != 4 || cycleErr.Cycle[0] != cycleErr.Cycle[3] {
+		t.Errorf("Incorrect cycle: %v", cycleErr.Cycle)
+	}
+}
@@ -0,0 +1,37 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ErrProtectedEnvironment error = errors.New("refusing to run a destructive command in a protected environment")
+
+// Environments where Fresh and Reset refuse to run unless
+// destructive commands are explicitly allowed
+var DefaultProtectedEnvironments = []string{"production", "prod"}
+
+// Whether the current environment is protected
+func (m *Migrations) Protected() bool {
+	if m.environment == "" {
+		return false
+	}
+
+	return slices.ContainsFunc(m.protectedEnvs, func(env string) bool {
+		return strings.EqualFold(env, m.environment)
+	})
+}
+
+func (m *Migrations) Environment() string {
+	return m.environment
+}
+
+func (m *Migrations) checkDestructive(command string) error {
+	if m.Protected() && !m.allowDestructive {
+		return fmt.Errorf("%w: %s in %s", ErrProtectedEnvironment, command, m.environment)
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+
+	"github.com/javif89/migrate/database"
+)
+
+// Column the down SQL is stored in, see WithStoreDownSQL
+var downColumn = "down_sql"
+
+// Column with the checksum of repeatable migrations
+var checksumColumn = "checksum"
+
+// A row in the migrations table
+type record struct {
+	Name  string
+	Batch int
+	// Down SQL stored when the migration was applied
+	Down sql.NullString
+	// Checksum of a repeatable migration when it was last applied
+	Checksum sql.NullString
+}
+
+// Rows in the migrations table in the order they were applied.
+// None when the table hasn't been created yet.
+func (m *Migrations) records() ([]record, error) {
+	if !m.hasTable() {
+		return []record{}, nil
+	}
+
+	// Optional columns are only added when they are needed
+	optional := map[string]bool{
+		downColumn:     m.hasColumn(downColumn),
+		checksumColumn: m.hasColumn(checksumColumn),
+	}
+
+	columns := "migration, batch"
+	for _, c := range []string{downColumn, checksumColumn} {
+		if optional[c] {
+			columns += ", " + c
+		}
+	}
+
+	rows, err := m.driver.GetConnection().Query(fmt.Sprintf("select %s from %s order by id", columns, m.table))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	records := []record{}
+
+	for rows.Next() {
+		var r record
+		var batch sql.NullInt64
+
+		dest := []any{&r.Name, &batch}
+
+		if optional[downColumn] {
+			dest = append(dest, &r.Down)
+		}
+
+		if optional[checksumColumn] {
+			dest = append(dest, &r.Checksum)
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		r.Batch = int(batch.Int64)
+		records = append(records, r)
+	}
+
+	return records, rows.Err()
+}
+
+// Applied migrations by name
+func (m *Migrations) recordsByName() (map[string]record, error) {
+	records, err := m.records()
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string]record{}
+	for _, r := range records {
+		byName[r.Name] = r
+	}
+
+	return byName, nil
+}
+
+// Whether the migrations table exists. Planning reads it without
+// creating it, it's only created when migrations run.
+func (m *Migrations) hasTable() bool {
+	return m.hasColumn("migration")
+}
+
+// Placeholder for the nth argument of a query on the driver
+func (m *Migrations) placeholder(n int) string {
+	if b, ok := m.driver.(database.Binder); ok {
+		return b.Placeholder(n)
+	}
+
+	return "?"
+}
+
+func (m *Migrations) hasColumn(column string) bool {
+	rows, err := m.driver.GetConnection().Query(fmt.Sprintf("select %s from %s where 1 = 0", column, m.table))
+	if err != nil {
+		return false
+	}
+
+	rows.Close()
+
+	return true
+}
+
+// Add a column to the migrations table unless it's already there,
+// so tables created by older versions keep working
+func (m *Migrations) ensureColumn(column string, definition string) error {
+	if m.hasColumn(column) {
+		return nil
+	}
+
+	_, err := m.driver.GetConnection().Exec(fmt.Sprintf("alter table %s add column %s %s", m.table, column, definition))
+
+	return err
+}
+
+// Create the migrations table and the optional columns
+// the steps need before running them
+func (m *Migrations) prepareTable(steps []Step) error {
+	if err := m.driver.CreateMigrationsTable(m.table); err != nil {
+		return err
+	}
+
+	if m.storeDown {
+		if err := m.ensureColumn(downColumn, "text"); err != nil {
+			return err
+		}
+	}
+
+	if slices.ContainsFunc(steps, func(s Step) bool { return s.Migration.Repeatable() }) {
+		return m.ensureColumn(checksumColumn, "varchar(64)")
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Direction string
+
+var Up Direction = "up"
+var Down Direction = "down"
+
+// Passed to the lifecycle hooks
+type Event struct {
+	// The migration being run. Nil for batch events.
+	Migration *Migration
+	Direction Direction
+	Batch     int
+	// How long the migration or batch took. Zero for Before* events.
+	Duration time.Duration
+	// Only set for OnError
+	Err error
+}
+
+// Callbacks run around migrations. Any of them can be nil.
+type Hooks struct {
+	BeforeMigration func(Event)
+	AfterMigration  func(Event)
+	// Called when a migration fails, before the error is returned
+	OnError     func(Event)
+	BeforeBatch func(Event)
+	// Only called when every migration in the batch succeeded
+	AfterBatch func(Event)
+}
+
+func (h Hooks) beforeMigration(e Event) {
+	if h.BeforeMigration != nil {
+		h.BeforeMigration(e)
+	}
+}
+
+func (h Hooks) afterMigration(e Event) {
+	if h.AfterMigration != nil {
+		h.AfterMigration(e)
+	}
+}
+
+func (h Hooks) onError(e Event) {
+	if h.OnError != nil {
+		h.OnError(e)
+	}
+}
+
+func (h Hooks) beforeBatch(e Event) {
+	if h.BeforeBatch != nil {
+		h.BeforeBatch(e)
+	}
+}
+
+func (h Hooks) afterBatch(e Event) {
+	if h.AfterBatch != nil {
+		h.AfterBatch(e)
+	}
+}
+
+// Folder with the SQL hook files
+func (m *Migrations) hooksPath() string {
+	if m.HooksPath != "" {
+		return m.HooksPath
+	}
+
+	return m.join(m.path, "hooks")
+}
+
+// Run one of the SQL hook files if it exists. when is either
+// before or after, so we end up with before_migrate.sql,
+// after_rollback.sql and so on.
+func (m *Migrations) runHookFile(when string, dir Direction) error {
+	run := "migrate"
+	if dir == Down {
+		run = "rollback"
+	}
+
+	path := m.join(m.hooksPath(), fmt.Sprintf("%s_%s.sql", when, run))
+	content, err := m.readFile(path)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	q := strings.TrimSpace(string(content))
+
+	if q == "" {
+		return nil
+	}
+
+	m.logger().Debug("running hook file", "hook", filepath.Base(path))
+
+	if err := m.driver.Run(q); err != nil {
+		return fmt.Errorf("failed running hook %s: %w", filepath.Base(path), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,382 @@
+package migrate
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/javif89/migrate/database"
+)
+
+// A problem found by Lint
+type LintIssue struct {
+	// Path of the migration file
+	Path string
+	Line int
+	// Name of the check that found it, e.g. empty-down
+	Rule    string
+	Message string
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%s:%d: %s (%s)", i.Path, i.Line, i.Message, i.Rule)
+}
+
+// A check run by Lint on every migration. Implement it to add
+// your own and pass them with WithLintRules.
+type LintRule interface {
+	// Short name used in reports and to ignore the rule, e.g. drop-column
+	Name() string
+	// Problems found in the file. Lint fills in the path and rule.
+	Check(f *LintFile) []LintIssue
+}
+
+// A migration split into statements, for rules to check
+type LintFile struct {
+	Migration Migration
+	// Driver the migration runs on
+	Driver database.DriverName
+	Up     []LintStatement
+	Down   []LintStatement
+	// Line of the -- DOWN -- marker, or the end of the file
+	// when there isn't one
+	DownLine int
+}
+
+// A statement and the line it starts on. Comments are left out.
+type LintStatement struct {
+	SQL  string
+	Line int
+}
+
+// Rules used unless WithLintRules says otherwise
+var DefaultLintRules = []LintRule{
+	irreversibleRule{},
+	emptyDownRule{},
+	mysqlAlgorithmRule{},
+	notNullDefaultRule{},
+	dropColumnRule{},
+}
+
+// Check every migration for problems that should be caught before
+// they are merged, e.g. in CI. Nothing is run on the database.
+// Variants for every driver are checked as the driver they are for,
+// whatever we are connected to. Migrations can skip rules with
+// -- migrate:lint-ignore <rule>.
+func (m *Migrations) Lint() ([]LintIssue, error) {
+	migrations, err := m.readMigrationFiles(false)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(migrations, func(a, b Migration) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+
+	rules := m.lintRules
+	if rules == nil {
+		rules = DefaultLintRules
+	}
+
+	issues := []LintIssue{}
+
+	for _, mg := range migrations {
+		if d := mg.Dialect(); d != "" {
+			mg.driver = d
+		}
+
+		content, err := mg.GetContent()
+		if err != nil {
+			return nil, err
+		}
+
+		d, err := mg.Directives()
+		if err != nil {
+			return nil, err
+		}
+
+		f := parseLintFile(mg, content)
+		found := []LintIssue{}
+
+		for _, r := range rules {
+			if slices.Contains(m.lintIgnore, r.Name()) || slices.Contains(d.LintIgnore, r.Name()) {
+				continue
+			}
+
+			for _, i := range r.Check(f) {
+				i.Path = mg.Path
+				i.Rule = r.Name()
+				found = append(found, i)
+			}
+		}
+
+		sort.SliceStable(found, func(a, b int) bool { return found[a].Line < found[b].Line })
+		issues = append(issues, found...)
+	}
+
+	return issues, nil
+}
+
+// Split the UP and DOWN sections into statements, keeping track of
+// the lines they start on. Sections for other drivers are left out.
+func parseLintFile(mg Migration, content string) *LintFile {
+	f := &LintFile{
+		Migration: mg,
+		Driver:    mg.driver,
+		DownLine:  strings.Count(content, "\n") + 1,
+	}
+
+	var section *[]LintStatement
+	var stmt strings.Builder
+	start := 0
+	keep := true
+	quote := rune(0)
+
+	flush := func() {
+		if sql := strings.TrimSpace(stmt.String()); sql != "" && section != nil {
+			*section = append(*section, LintStatement{SQL: sql, Line: start})
+		}
+
+		stmt.Reset()
+	}
+
+	for i, line := range strings.Split(content, "\n") {
+		marker := strings.TrimSpace(line)
+
+		if quote == 0 {
+			switch {
+			case strings.HasPrefix(marker, "-- UP --"):
+				flush()
+				section = &f.Up
+				continue
+			case strings.HasPrefix(marker, "-- DOWN --"):
+				flush()
+				section = &f.Down
+				f.DownLine = i + 1
+				continue
+			case strings.HasPrefix(marker, "-- IF ") && strings.HasSuffix(marker, " --"):
+				drivers := splitList(strings.TrimSuffix(strings.TrimPrefix(marker, "-- IF "), " --"))
+				keep = slices.Contains(drivers, string(mg.driver))
+				continue
+			case marker == "-- END IF --":
+				keep = true
+				continue
+			case !keep || marker == "" || strings.HasPrefix(marker, "--"):
+				continue
+			}
+		}
+
+		for j, c := range line {
+			// The rest of the line is a comment
+			if quote == 0 && strings.HasPrefix(line[j:], "--") {
+				break
+			}
+
+			if stmt.Len() == 0 {
+				if c == ' ' || c == '\t' || c == '\r' {
+					continue
+				}
+
+				start = i + 1
+			}
+
+			switch {
+			case quote != 0 && c == quote:
+				quote = 0
+			case quote == 0 && (c == '\'' || c == '"' || c == '`'):
+				quote = c
+			case quote == 0 && c == ';':
+				flush()
+				continue
+			}
+
+			stmt.WriteRune(c)
+		}
+
+		if stmt.Len() > 0 {
+			stmt.WriteRune('\n')
+		}
+	}
+
+	flush()
+
+	return f
+}
+
+type irreversibleRule struct{}
+
+func (irreversibleRule) Name() string { return "irreversible" }
+
+func (irreversibleRule) Check(f *LintFile) []LintIssue {
+	if !f.Migration.Irreversible() {
+		return nil
+	}
+
+	return []LintIssue{{
+		Line:    f.DownLine,
+		Message: "migration is irreversible, rolling it back will fail",
+	}}
+}
+
+type emptyDownRule struct{}
+
+func (emptyDownRule) Name() string { return "empty-down" }
+
+func (emptyDownRule) Check(f *LintFile) []LintIssue {
+	if f.Migration.Irreversible() || len(f.Down) > 0 {
+		return nil
+	}
+
+	return []LintIssue{{
+		Line:    f.DownLine,
+		Message: "down section is empty. Write the SQL to undo it or mark it -- DOWN -- irreversible",
+	}}
+}
+
+var algorithmPattern = regexp.MustCompile(`(?i)\balgorithm\s*=\s*(inplace|instant)\b`)
+
+// Without it MySQL may copy the whole table, locking it while it does
+type mysqlAlgorithmRule struct{}
+
+func (mysqlAlgorithmRule) Name() string { return "mysql-algorithm" }
+
+func (mysqlAlgorithmRule) Check(f *LintFile) []LintIssue {
+	if f.Driver != database.DriverMysql {
+		return nil
+	}
+
+	issues := []LintIssue{}
+
+	for _, s := range slices.Concat(f.Up, f.Down) {
+		if table, _, ok := alterTable(s.SQL); ok && !algorithmPattern.MatchString(s.SQL) {
+			issues = append(issues, LintIssue{
+				Line:    s.Line,
+				Message: fmt.Sprintf("alter table %s without ALGORITHM=INPLACE may copy and lock the table", table),
+			})
+		}
+	}
+
+	return issues
+}
+
+// Existing rows have no value for the new column
+type notNullDefaultRule struct{}
+
+func (notNullDefaultRule) Name() string { return "not-null-default" }
+
+func (notNullDefaultRule) Check(f *LintFile) []LintIssue {
+	issues := []LintIssue{}
+
+	for _, s := range slices.Concat(f.Up, f.Down) {
+		table, clauses, _ := alterTable(s.SQL)
+
+		for _, c := range clauses {
+			column, ok := columnClause(c, "add")
+			if !ok || !strings.Contains(c, "not null") || strings.Contains(c, "default") {
+				continue
+			}
+
+			issues = append(issues, LintIssue{
+				Line:    s.Line,
+				Message: fmt.Sprintf("adds NOT NULL column %s to %s without a default", column, table),
+			})
+		}
+	}
+
+	return issues
+}
+
+// The app may still be reading the column. Only the UP
+// section is checked, down sections undo added columns.
+type dropColumnRule struct{}
+
+func (dropColumnRule) Name() string { return "drop-column" }
+
+func (dropColumnRule) Check(f *LintFile) []LintIssue {
+	issues := []LintIssue{}
+
+	for _, s := range f.Up {
+		table, clauses, _ := alterTable(s.SQL)
+
+		for _, c := range clauses {
+			if column, ok := columnClause(c, "drop"); ok {
+				issues = append(issues, LintIssue{
+					Line:    s.Line,
+					Message: fmt.Sprintf("drops column %s of %s. Make sure the app no longer uses it", column, table),
+				})
+			}
+		}
+	}
+
+	return issues
+}
+
+// Table and lowercased clauses of an ALTER TABLE statement
+func alterTable(sql string) (string, []string, bool) {
+	fields := strings.Fields(strings.ToLower(sql))
+
+	if len(fields) < 3 || fields[0] != "alter" || fields[1] != "table" {
+		return "", nil, false
+	}
+
+	clauses := []string{}
+	depth := 0
+	last := 0
+	rest := strings.Join(fields[3:], " ")
+
+	for i, c := range rest {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				clauses = append(clauses, strings.TrimSpace(rest[last:i]))
+				last = i + 1
+			}
+		}
+	}
+
+	clauses = append(clauses, strings.TrimSpace(rest[last:]))
+
+	return strings.Trim(fields[2], "`\""), clauses, true
+}
+
+// Words after ADD or DROP that mean it isn't about a column
+var notColumns = []string{"index", "key", "unique", "primary", "foreign", "constraint", "check", "fulltext", "spatial", "partition"}
+
+// Column added or dropped by an ALTER TABLE clause
+func columnClause(clause string, action string) (string, bool) {
+	fields := strings.Fields(clause)
+
+	if len(fields) < 2 || fields[0] != action {
+		return "", false
+	}
+
+	fields = fields[1:]
+	if fields[0] == "column" {
+		if len(fields) < 2 {
+			return "", false
+		}
+
+		fields = fields[1:]
+	} else if slices.Contains(notColumns, fields[0]) {
+		return "", false
+	}
+
+	if fields[0] == "if" {
+		// if [not] exists
+		i := slices.Index(fields, "exists")
+
+		if i == -1 || i+1 == len(fields) {
+			return "", false
+		}
+
+		fields = fields[i+1:]
+	}
+
+	return strings.Trim(fields[0], "`\""), true
+}
@@ -0,0 +1,188 @@
+package migrate
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javif89/migrate/database"
+)
+
+func TestLintDownSections(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	writeMigration(t, mgf, "2024_01_01_000000_users", "-- UP --\ncreate table users (id int);\n-- DOWN --\ndrop table users;")
+	writeMigration(t, mgf, "2024_01_02_000000_backfill", "-- UP --\ninsert into users values (1);\n\n-- DOWN -- irreversible\n")
+	writeMigration(t, mgf, "2024_01_03_000000_noop", "-- UP --\nselect 1;\n-- DOWN --\n")
+
+	issues, err := m.Lint()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(issues) != 2 {
+		t.Fatalf("Expected 2 issues, got %v", issues)
+	}
+
+	if issues[0].Rule != "irreversible" || issues[0].Line != 4 {
+		t.Errorf("Incorrect issue for an irreversible migration: %v", issues[0])
+	}
+
+	if issues[1].Rule != "empty-down" || issues[1].Line != 3 {
+		t.Errorf("Incorrect issue for an empty down section: %v", issues[1])
+	}
+}
+
+func TestLintRules(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+
+	writeMigration(t, mgf, "2024_01_01_000000_add_email", `-- UP --
+alter table users
+  add column email varchar(255) not null,
+  add column age int not null default 0,
+  add index users_age (age);
+-- DOWN --
+alter table users drop column email, drop column age;`)
+	writeMigration(t, mgf, "2024_01_02_000000_drop_legacy", "-- UP --\n-- The app stopped using it\nalter table users drop column legacy_id;\n-- DOWN --\nalter table users add column legacy_id int;")
+	writeMigration(t, mgf, "2024_01_03_000000_drop_old", "-- migrate:lint-ignore drop-column\n-- UP --\nalter table users drop old;\n-- DOWN --\nalter table users add old int;")
+
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	issues, err := m.Lint()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(issues) != 2 {
+		t.Fatalf("Expected 2 issues, got %v", issues)
+	}
+
+	if issues[0].Rule != "not-null-default" || issues[0].Line != 2 || !strings.Contains(issues[0].Message, "email") {
+		t.Errorf("Incorrect issue for a NOT NULL column: %v", issues[0])
+	}
+
+	if issues[1].Rule != "drop-column" || issues[1].Line != 3 || !strings.Contains(issues[1].Message, "legacy_id") {
+		t.Errorf("Incorrect issue for a dropped column: %v", issues[1])
+	}
+
+	m = New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	}, WithLintIgnore("not-null-default", "drop-column"), WithLintRules(append(DefaultLintRules, noSelectRule{})...))
+
+	writeMigration(t, mgf, "2024_01_04_000000_check", "-- UP --\nselect 1;\n-- DOWN --\nselect 1;")
+
+	if issues, _ := m.Lint(); len(issues) != 1 || issues[0].Rule != "no-select" || issues[0].Line != 2 {
+		t.Errorf("Expected only the custom rule to report, got %v", issues)
+	}
+}
+
+func TestLintMysqlAlgorithm(t *testing.T) {
+	f := parseLintFile(Migration{Path: "add_email.sql", driver: database.DriverMysql}, `-- UP --
+alter table users add column email text, algorithm=inplace, lock=none;
+-- IF mysql --
+alter table users add index users_email (email(20));
+-- END IF --
+-- DOWN --
+alter table users drop column email, ALGORITHM = INSTANT;`)
+
+	if len(f.Up) != 2 || len(f.Down) != 1 || f.Up[1].Line != 4 || f.DownLine != 6 {
+		t.Fatalf("Incorrect statements: %+v", f)
+	}
+
+	issues := mysqlAlgorithmRule{}.Check(f)
+
+	if len(issues) != 1 || issues[0].Line != 4 {
+		t.Errorf("Expected the index without an algorithm to be reported, got %v", issues)
+	}
+
+	f.Driver = database.DriverSqlite
+
+	if issues := (mysqlAlgorithmRule{}).Check(f); len(issues) != 0 {
+		t.Errorf("The rule only applies to MySQL, got %v", issues)
+	}
+}
+
+func TestLintVariantsForOtherDrivers(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+
+	writeMigration(t, mgf, "2024_01_01_000000_users.mysql", "-- UP --\nalter table users add column age int not null, drop column name;\n-- DOWN --\n")
+	writeMigration(t, mgf, "2024_01_01_000000_users.sqlite", "-- UP --\nalter table users add column age int not null default 0;\n-- DOWN --\nalter table users drop column age;")
+
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	issues, err := m.Lint()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules := []string{}
+	for _, i := range issues {
+		if !strings.HasSuffix(i.Path, "users.mysql.sql") {
+			t.Errorf("Only the MySQL variant has problems: %v", i)
+		}
+
+		rules = append(rules, i.Rule)
+	}
+
+	if strings.Join(rules, " ") != "mysql-algorithm not-null-default drop-column empty-down" {
+		t.Errorf("The MySQL variant should be checked as MySQL with a SQLite connection, got %v", issues)
+	}
+}
+
+func TestLintInlineComments(t *testing.T) {
+	f := parseLintFile(Migration{Path: "t.sql", driver: database.DriverSqlite}, `-- UP --
+create table t (id int); -- don't forget the index
+insert into t values (1) -- it's the first row
+;
+-- DOWN --
+drop table t;`)
+
+	if len(f.Up) != 2 || f.Up[1].SQL != "insert into t values (1)" || len(f.Down) != 1 || f.DownLine != 5 {
+		t.Errorf("Apostrophes in comments should not open a quote: %+v", f)
+	}
+}
+
+func TestLintColumnClauses(t *testing.T) {
+	tests := map[string]string{
+		"add column if not exists age int not null": "age",
+		"add if not exists age int":                 "age",
+		"drop column if exists name":                "name",
+		"drop column if name":                       "",
+		"drop column if exists":                     "",
+	}
+
+	for clause, expected := range tests {
+		action, _, _ := strings.Cut(clause, " ")
+
+		if column, ok := columnClause(clause, action); column != expected || ok != (expected != "") {
+			t.Errorf("Incorrect column for %q: %q %v", clause, column, ok)
+		}
+	}
+}
+
+// Example of a project specific rule
+type noSelectRule struct{}
+
+func (noSelectRule) Name() string { return "no-select" }
+
+func (noSelectRule) Check(f *LintFile) []LintIssue {
+	issues := []LintIssue{}
+
+	for _, s := range f.Up {
+		if strings.HasPrefix(strings.ToLower(s.SQL), "select") {
+			issues = append(issues, LintIssue{Line: s.Line, Message: "select in a migration"})
+		}
+	}
+
+	return issues
+}
@@ -0,0 +1,93 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrLockTimeout error = errors.New("timed out waiting for the migration lock")
+
+// Prevents two processes from migrating the same database at once.
+// Migrate, Rollback and Fresh hold the lock while they run.
+type Locker interface {
+	Lock() error
+	Unlock() error
+}
+
+// Locker backed by MySQL's GET_LOCK. Locks in MySQL belong to a
+// session, so the lock is held on a dedicated connection taken
+// out of the pool until Unlock is called.
+type MysqlLock struct {
+	db      *sql.DB
+	name    string
+	timeout time.Duration
+	conn    *sql.Conn
+}
+
+func NewMysqlLock(db *sql.DB, name string, timeout time.Duration) *MysqlLock {
+	return &MysqlLock{
+		db:      db,
+		name:    name,
+		timeout: timeout,
+	}
+}
+
+func (l *MysqlLock) Lock() error {
+	ctx := context.Background()
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	var ok sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", l.name, int(l.timeout.Seconds())).Scan(&ok)
+
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	// 0 means we timed out and NULL means something went wrong
+	if !ok.Valid || ok.Int64 != 1 {
+		conn.Close()
+		return fmt.Errorf("%w: %s", ErrLockTimeout, l.name)
+	}
+
+	l.conn = conn
+
+	return nil
+}
+
+func (l *MysqlLock) Unlock() error {
+	if l.conn == nil {
+		return nil
+	}
+
+	defer func() {
+		l.conn.Close()
+		l.conn = nil
+	}()
+
+	_, err := l.conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", l.name)
+
+	return err
+}
+
+// Run f while holding the lock, if there is one
+func (m *Migrations) withLock(f func() error) error {
+	if m.lock == nil {
+		return f()
+	}
+
+	if err := m.lock.Lock(); err != nil {
+		return fmt.Errorf("failed acquiring migration lock: %w", err)
+	}
+
+	defer m.lock.Unlock()
+
+	return f()
+}
@@ -1,120 +1,253 @@
 package migrate
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"io/fs"
 	"log"
-	"os"
-	"path/filepath"
+	"log/slog"
 	"slices"
-	"time"
+	"strings"
 
 	"github.com/javif89/migrate/database"
 )
 
 var ErrNoMigrations error = errors.New("no migrations") 
 var ErrNoMigrationsToRun error = errors.New("nothing to migrate")
+var ErrOutOfOrder error = errors.New("out of order migrations")
+
+// Migration files are prefixed with a timestamp in this format
+var timestampFormat = "2006_01_02_150405"
+
+// What to do when a pending migration is older than the newest
+// migration already applied. This usually happens when a feature
+// branch with older migrations gets merged.
+type OutOfOrderPolicy string
+
+var OutOfOrderWarn OutOfOrderPolicy = "warn" // Run them but print a warning. The default
+var OutOfOrderStrict OutOfOrderPolicy = "strict" // Refuse to migrate
+var OutOfOrderAllow OutOfOrderPolicy = "allow" // Run them silently
+
+// Returned by Migrate under OutOfOrderStrict. Lists the offending migrations.
+type OutOfOrderError struct {
+	Migrations []string
+}
+
+func (e *OutOfOrderError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrOutOfOrder, strings.Join(e.Migrations, ", "))
+}
+
+func (e *OutOfOrderError) Unwrap() error {
+	return ErrOutOfOrder
+}
+
+// Default folder to read migrations from
+var DefaultPath = "./database/migrations"
+
+// Default table to keep track of migrations in
+var DefaultTable = "migrations"
 
 type Migrations struct {
 	path string
 	driver database.Driver
+	dialect database.DriverName
+	table string
+	fsys fs.FS
+	lock Locker
+	wipe database.WipeOptions
+	environment string
+	protectedEnvs []string
+	allowDestructive bool
+	storeDown bool
+	tags []string
+	modulePaths []string
+	vars map[string]string
+	lintRules []LintRule
+	lintIgnore []string
+
+	// Policy for pending migrations older than the last applied one
+	OutOfOrder OutOfOrderPolicy
+
+	// Folder with user defined migration templates.
+	// Defaults to a templates folder inside the migrations path.
+	TemplatesPath string
+
+	// Callbacks run around each migration and batch
+	Hooks Hooks
+
+	// Structured logger for migration events. Defaults to slog.Default()
+	Logger *slog.Logger
+
+	// Folder with SQL files run around every migrate or rollback
+	// (before_migrate.sql, after_rollback.sql...). Defaults to a
+	// hooks folder inside the migrations path.
+	HooksPath string
 }
 
-func New(path string, driver database.DriverName, cfg database.Config) *Migrations {
-	d, err := database.GetDriver(driver, cfg)
+func New(path string, driver database.DriverName, cfg database.Config, opts ...Option) *Migrations {
+	m, err := Open(path, driver, cfg, opts...)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	return &Migrations{
-		path: path,
-		driver: d,
+	return m
+}
+
+// Same as New but returns an error instead of exiting when
+// the driver is unknown or the connection can't be opened
+func Open(path string, driver database.DriverName, cfg database.Config, opts ...Option) (*Migrations, error) {
+	d, err := database.GetDriver(driver, cfg)
+	if err != nil {
+		return nil, err
 	}
+
+	return newMigrations(d, driver, append([]Option{WithPath(path)}, opts...)), nil
 }
 
-func (m *Migrations) CreateMigration(name string) {
-	n := getMigrationFileName(name)
-	filename := fmt.Sprintf("%s.sql", n)
-	path := filepath.Join(m.path, filename)
+// Create a Migrations instance that runs on an existing connection,
+// e.g. the one your application already configured. dialect picks
+// the driver to use for it.
+func NewWithDB(db *sql.DB, dialect database.DriverName, opts ...Option) (*Migrations, error) {
+	d, err := database.WrapDriver(dialect, db, database.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	return newMigrations(d, dialect, opts), nil
+}
+
+func newMigrations(d database.Driver, dialect database.DriverName, opts []Option) *Migrations {
+	m := &Migrations{
+		driver: d,
+		dialect: dialect,
+		table: DefaultTable,
+		protectedEnvs: DefaultProtectedEnvironments,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if m.path == "" && m.fsys != nil {
+		m.path = "."
+	}
 
-	content := "-- UP --\n\n-- DOWN --"
+	if m.path == "" {
+		m.path = DefaultPath
+	}
+
+	return m
+}
+
+// The database connection, e.g. to seed data in tests
+func (m *Migrations) DB() *sql.DB {
+	return m.driver.GetConnection()
+}
 
-	saveFile(path, content)
+// Close the database connection. Connections passed to
+// NewWithDB are left open since they belong to the caller.
+func (m *Migrations) Close() error {
+	return m.driver.Close()
 }
 
 func (m *Migrations) Migrate() error {
-	if err := m.driver.CreateMigrationsTable(); err != nil {
+	return m.withLock(m.migrate)
+}
+
+func (m *Migrations) migrate() error {
+	p, err := m.Plan(Up, "")
+	if err != nil {
 		return err
 	}
 
-	migrations, err := m.GetUnexecutedMigrations()
-	batch := m.nextBatch()
+	return p.apply()
+}
 
+// Run the pending migrations up to and including name
+func (m *Migrations) MigrateTo(name string) error {
+	p, err := m.Plan(Up, name)
 	if err != nil {
 		return err
 	}
 
-	if len(migrations) == 0 {
-		return ErrNoMigrationsToRun
-	}
+	return p.Apply()
+}
 
-	for _, mg := range migrations{
-		fmt.Println(mg.Name())
-		q := mg.GetUpQuery()
-		err := m.driver.Run(q)
+func (m *Migrations) Rollback() error {
+	return m.withLock(m.rollback)
+}
 
-		if err != nil {
-			fmt.Printf("Failed migrating %s\n", mg.Name())
-			log.Fatal(err)
-			break
-		}
+func (m *Migrations) rollback() error {
+	// Only the migrations from the last batch
+	p, err := m.Plan(Down, "")
+	if err != nil {
+		return err
+	}
 
-		m.logMigration(mg, batch)
+	if p.Empty() {
+		return nil
 	}
 
-	return nil
+	return p.apply()
 }
 
-func (m *Migrations) Rollback() error {
-	migrations, err := m.GetMigrationsReverse()
+func (m *Migrations) logger() *slog.Logger {
+	if m.Logger != nil {
+		return m.Logger
+	}
 
-	if err != nil {
+	return slog.Default()
+}
+
+// Roll back every batch
+func (m *Migrations) Reset() error {
+	if err := m.checkDestructive("reset"); err != nil {
 		return err
 	}
 
-	// Select only the migrations from the last batch
-	mgs := m.GetMigrationsInBatch(m.currentBatch())
+	return m.withLock(func() error {
+		for {
+			batch := m.currentBatch()
 
-	for _, mg := range migrations {
-		if !slices.Contains(mgs, mg.Name()) {
-			continue
-		}
-		
-		fmt.Println(mg.Name())
-		q := mg.GetDownQuery()
-		err := m.driver.Run(q)
+			if batch == 0 {
+				return nil
+			}
 
-		if err != nil {
-			log.Fatal(err)
-			break
+			if err := m.rollback(); err != nil {
+				return err
+			}
+
+			// Nothing we can roll back in this batch, stop
+			// instead of trying the same one forever
+			if m.currentBatch() == batch {
+				return fmt.Errorf("could not roll back batch %d", batch)
+			}
 		}
+	})
+}
 
-		m.removeMigration(mg)
+// Drop all tables and migrate
+func (m *Migrations) Fresh() error {
+	if err := m.checkDestructive("fresh"); err != nil {
+		return err
 	}
 
-	return nil
-}
+	return m.withLock(func() error {
+		if err := m.driver.Wipe(m.wipe); err != nil {
+			return err
+		}
 
-// Drop all tables and migrate
-func (m *Migrations) Fresh() {
-	m.driver.Wipe()
-	m.Migrate()
+		return m.migrate()
+	})
 }
 
-// Get migrations in order
+// Get the migrations for the driver and environment we are running in,
+// in order. They are sorted by name, except where a migration requires
+// one that would otherwise run after it.
 func (m *Migrations) GetMigrations() ([]Migration, error) {
-	files, err := os.ReadDir(m.path)
+	all, err := m.allMigrations()
 
 	if err != nil {
 		return nil, err
@@ -122,10 +255,14 @@ func (m *Migrations) GetMigrations() ([]Migration, error) {
 
 	migrations := []Migration{}
 
-	for _, f := range files {
-		if !f.IsDir() {
-			path := filepath.Join(m.path, f.Name())
-			migrations = append(migrations, Migration{Path: path})
+	for _, mg := range all {
+		d, err := mg.Directives()
+		if err != nil {
+			return nil, err
+		}
+
+		if m.inEnvironment(d) {
+			migrations = append(migrations, mg)
 		}
 	}
 
@@ -137,6 +274,85 @@ func (m *Migrations) GetMigrations() ([]Migration, error) {
 	return migrations, nil
 }
 
+// Versioned migrations for our driver in every environment, in order
+func (m *Migrations) allMigrations() ([]Migration, error) {
+	migrations, err := m.migrationFiles(false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sortByDependencies(migrations)
+}
+
+// Versioned or repeatable migration files in every migrations folder,
+// sorted by name. When a migration has variants for several drivers
+// (name.mysql.sql, name.sqlite.sql) only the one for our driver is
+// kept, falling back to the plain file (name.sql).
+func (m *Migrations) migrationFiles(repeatable bool) ([]Migration, error) {
+	migrations, err := m.readMigrationFiles(repeatable)
+	if err != nil {
+		return nil, err
+	}
+
+	variants := map[string]Migration{}
+
+	for _, mg := range migrations {
+		if mg.Dialect() == m.dialect {
+			variants[mg.Name()] = mg
+		}
+	}
+
+	// Drop the variants for other drivers and plain files
+	// that have a variant for ours
+	migrations = slices.DeleteFunc(migrations, func(mg Migration) bool {
+		v, ok := variants[mg.Name()]
+
+		if mg.Dialect() == "" {
+			return ok && v.Path != mg.Path
+		}
+
+		return mg.Dialect() != m.dialect
+	})
+
+	slices.SortStableFunc(migrations, func(a, b Migration) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+
+	// They'd share a row in the migrations table
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Name() == migrations[i-1].Name() {
+			return nil, fmt.Errorf("%w: %s and %s", ErrDuplicateMigration, migrations[i-1].Path, migrations[i].Path)
+		}
+	}
+
+	return migrations, nil
+}
+
+// Every versioned or repeatable migration file, variants for
+// every driver included, in the order the folders are read
+func (m *Migrations) readMigrationFiles(repeatable bool) ([]Migration, error) {
+	migrations := []Migration{}
+
+	for _, dir := range append([]string{m.path}, m.modulePaths...) {
+		files, err := m.readDir(dir)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range files {
+			mg := Migration{Path: m.join(dir, f.Name()), fsys: m.fsys, driver: m.dialect, vars: m.vars}
+
+			if !f.IsDir() && mg.Repeatable() == repeatable {
+				migrations = append(migrations, mg)
+			}
+		}
+	}
+
+	return migrations, nil
+}
+
 // Get migrations in reverse order. Mostly for rollbacks
 func (m *Migrations) GetMigrationsReverse() ([]Migration, error) {
 	mg, err := m.GetMigrations()
@@ -171,20 +387,109 @@ func (m *Migrations) GetUnexecutedMigrations() ([]Migration, error) {
 	return un, nil
 }
 
-func (m *Migrations) logMigration(mg Migration, batch int) {
-	q := fmt.Sprintf("insert into migrations (migration, batch) values ('%s', %d)", mg.Name(), batch)
-	m.driver.Run(q)
+// Get the pending migrations that are older than the newest
+// migration that has already been applied
+func (m *Migrations) GetOutOfOrderMigrations() ([]Migration, error) {
+	un, err := m.GetUnexecutedMigrations()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.outOfOrder(un), nil
+}
+
+func (m *Migrations) outOfOrder(pending []Migration) []Migration {
+	existing := m.GetExistingMigrations()
+
+	if len(existing) == 0 {
+		return []Migration{}
+	}
+
+	// Migration names start with a timestamp so the newest one is
+	// also the last one alphabetically. Repeatable ones don't count.
+	existing = slices.DeleteFunc(existing, isRepeatable)
+
+	if len(existing) == 0 {
+		return []Migration{}
+	}
+
+	newest := slices.Max(existing)
+
+	ooo := []Migration{}
+
+	for _, mg := range pending {
+		if mg.Name() < newest {
+			ooo = append(ooo, mg)
+		}
+	}
+
+	return ooo
+}
+
+func (m *Migrations) checkOutOfOrder(pending []Migration) error {
+	if m.OutOfOrder == OutOfOrderAllow {
+		return nil
+	}
+
+	ooo := m.outOfOrder(pending)
+
+	if len(ooo) == 0 {
+		return nil
+	}
+
+	names := []string{}
+	for _, mg := range ooo {
+		names = append(names, mg.Name())
+	}
+
+	if m.OutOfOrder == OutOfOrderStrict {
+		return &OutOfOrderError{Migrations: names}
+	}
+
+	for _, n := range names {
+		m.logger().Warn("migration is older than the last applied migration", "migration", n)
+	}
+
+	return nil
+}
+
+func (m *Migrations) logMigration(ctx context.Context, ex execer, mg Migration, batch int) error {
+	if m.storeDown {
+		// Irreversible migrations store NULL so they can't be
+		// rolled back by mistake once their file is gone
+		var down any
+		if !mg.Irreversible() {
+			down = mg.GetDownQuery()
+		}
+
+		q := fmt.Sprintf("insert into %s (migration, batch, %s) values (%s, %s, %s)", m.table, downColumn, m.placeholder(1), m.placeholder(2), m.placeholder(3))
+		_, err := ex.ExecContext(ctx, q, mg.Name(), batch, down)
+
+		return err
+	}
+
+	q := fmt.Sprintf("insert into %s (migration, batch) values ('%s', %d)", m.table, mg.Name(), batch)
+	_, err := ex.ExecContext(ctx, q)
+
+	return err
 }
 
-func (m *Migrations) removeMigration(mg Migration) {
-	q := fmt.Sprintf("delete from migrations where migration = '%s'", mg.Name())
-	m.driver.Run(q)
+func (m *Migrations) removeMigration(ctx context.Context, ex execer, mg Migration) error {
+	q := fmt.Sprintf("delete from %s where migration = '%s'", m.table, mg.Name())
+	_, err := ex.ExecContext(ctx, q)
+
+	return err
 }
 
 func (m *Migrations) currentBatch() int {
+	if !m.hasTable() {
+		return 0
+	}
+
 	db := m.driver.GetConnection()
 
-	r := db.QueryRow("select max(batch) from migrations")
+	r := db.QueryRow(fmt.Sprintf("select max(batch) from %s", m.table))
 
 	var batch sql.NullInt64
 	err := r.Scan(&batch)
@@ -205,9 +510,13 @@ func (m *Migrations) nextBatch() int {
 }
 
 func (m *Migrations) GetExistingMigrations() []string {
+	if !m.hasTable() {
+		return []string{}
+	}
+
 	db := m.driver.GetConnection()
 
-	r, err := db.Query("select migration from migrations")
+	r, err := db.Query(fmt.Sprintf("select migration from %s", m.table))
 
 	// If we don't get any rows return 1
 	if err == sql.ErrNoRows {
@@ -241,7 +550,7 @@ func (m *Migrations) GetExistingMigrations() []string {
 func (m *Migrations) GetMigrationsInBatch(batch int) []string {
 	db := m.driver.GetConnection()
 
-	r, err := db.Query("select migration from migrations where batch = ?", batch)
+	r, err := db.Query(fmt.Sprintf("select migration from %s where batch = %s", m.table, m.placeholder(1)), batch)
 
 	if err == sql.ErrNoRows {
 		return []string{}
@@ -270,9 +579,3 @@ func (m *Migrations) GetMigrationsInBatch(batch int) []string {
 
 	return ms
 }
-
-func getMigrationFileName(name string) string {
-	time := time.Now().Format("2006_01_02_150405")
-
-	return fmt.Sprintf("%s_%s", time, name)
-}
\ No newline at end of file
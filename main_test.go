@@ -1,12 +1,18 @@
 package migrate
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/javif89/migrate/database"
 )
@@ -301,4 +307,432 @@ func TestGetMigrationsInBatch(t *testing.T) {
 	if len(mg) != 1 {
 		t.Errorf("Incorrect number of migrations in batch 2: %d", len(mg))
 	}
-}
\ No newline at end of file
+}
+
+func writeMigration(t *testing.T, dir string, name string, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, name+".sql"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOutOfOrderMigrations(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	writeMigration(t, mgf, "2024_01_02_000000_newer", "-- UP --\n\n-- DOWN --")
+	m.Migrate()
+
+	// Simulate merging a branch with an older migration
+	writeMigration(t, mgf, "2024_01_01_000000_older", "-- UP --\n\n-- DOWN --")
+	writeMigration(t, mgf, "2024_01_03_000000_newest", "-- UP --\n\n-- DOWN --")
+
+	ooo, _ := m.GetOutOfOrderMigrations()
+
+	if len(ooo) != 1 || !strings.Contains(ooo[0].Name(), "older") {
+		t.Fatalf("Incorrect out of order migrations: %v", ooo)
+	}
+
+	m.OutOfOrder = OutOfOrderStrict
+	err := m.Migrate()
+
+	var oooErr *OutOfOrderError
+	if !errors.As(err, &oooErr) || !errors.Is(err, ErrOutOfOrder) {
+		t.Fatalf("Expected an out of order error, got %v", err)
+	}
+
+	if len(oooErr.Migrations) != 1 || !strings.Contains(oooErr.Migrations[0], "older") {
+		t.Errorf("Out of order error does not list the migration: %v", oooErr.Migrations)
+	}
+
+	if len(m.GetExistingMigrations()) != 1 {
+		t.Errorf("Strict policy should not run any migrations")
+	}
+
+	m.OutOfOrder = OutOfOrderAllow
+
+	if err := m.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.GetExistingMigrations()) != 3 {
+		t.Errorf("Allow policy should run all pending migrations")
+	}
+}
+
+func TestCreateMigrationScaffolds(t *testing.T) {
+	d := t.TempDir()
+	m := New(filepath.Join(d, "migrations"), database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	conn := m.driver.GetConnection()
+
+	path, err := m.CreateMigrationFrom("create_users_table", CreateOptions{Table: "users", Create: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasSuffix(path, "_create_users_table.sql") {
+		t.Errorf("Incorrect migration path %s", path)
+	}
+
+	m.Migrate()
+
+	var name string
+	r := conn.QueryRow("select name from sqlite_master where type = 'table' and name = 'users'")
+	if err := r.Scan(&name); err != nil {
+		t.Fatalf("Create table scaffold did not create the table: %s", err)
+	}
+
+	m.Rollback()
+
+	r = conn.QueryRow("select name from sqlite_master where type = 'table' and name = 'users'")
+	if err := r.Scan(&name); err != sql.ErrNoRows {
+		t.Errorf("Create table scaffold did not drop the table on rollback")
+	}
+
+	path, _ = m.CreateMigrationFrom("add_email_to_users", CreateOptions{Table: "users"})
+	c, _ := os.ReadFile(path)
+
+	if !strings.Contains(string(c), `ALTER TABLE "users"`) {
+		t.Errorf("Alter table scaffold is not correct: %s", c)
+	}
+}
+
+func TestCreateMigrationUserTemplates(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	writeMigration(t, filepath.Join(mgf, "templates"), "seed", "-- UP --\n-- {{.Name}} {{.Table}} {{.Timestamp}}\n-- DOWN --")
+
+	path, err := m.CreateMigrationFrom("seed_users", CreateOptions{Table: "users", Template: "seed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, _ := os.ReadFile(path)
+	ts := strings.TrimSuffix(filepath.Base(path), "_seed_users.sql")
+
+	if string(c) != fmt.Sprintf("-- UP --\n-- seed_users users %s\n-- DOWN --", ts) {
+		t.Errorf("User template was not rendered correctly: %s", c)
+	}
+
+	// Templates should not be picked up as migrations
+	mg, _ := m.GetMigrations()
+	if len(mg) != 1 {
+		t.Errorf("Incorrect number of migrations: %d", len(mg))
+	}
+
+	if _, err := m.CreateMigrationFrom("missing", CreateOptions{Template: "missing"}); err == nil {
+		t.Errorf("Expected an error for a missing template")
+	}
+}
+
+func TestHooks(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	events := []string{}
+	record := func(name string) func(Event) {
+		return func(e Event) {
+			n := fmt.Sprintf("%s %s %d", name, e.Direction, e.Batch)
+			if e.Migration != nil {
+				n += " " + e.Migration.Name()
+			}
+
+			events = append(events, n)
+		}
+	}
+
+	m.Hooks = Hooks{
+		BeforeMigration: record("before"),
+		AfterMigration:  record("after"),
+		OnError:         record("error"),
+		BeforeBatch:     record("before_batch"),
+		AfterBatch:      record("after_batch"),
+	}
+
+	writeMigration(t, mgf, "2024_01_01_000000_first", "-- UP --\ncreate table first (id int);\n-- DOWN --\ndrop table first;")
+
+	if err := m.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	m.Rollback()
+
+	expected := []string{
+		"before_batch up 1",
+		"before up 1 2024_01_01_000000_first",
+		"after up 1 2024_01_01_000000_first",
+		"after_batch up 1",
+		"before_batch down 1",
+		"before down 1 2024_01_01_000000_first",
+		"after down 1 2024_01_01_000000_first",
+		"after_batch down 1",
+	}
+
+	if !slices.Equal(events, expected) {
+		t.Errorf("Hooks called incorrectly: %v", events)
+	}
+
+	events = []string{}
+	writeMigration(t, mgf, "2024_01_02_000000_broken", "-- UP --\nnot sql;\n-- DOWN --")
+
+	if err := m.Migrate(); err == nil {
+		t.Fatal("Expected the broken migration to fail")
+	}
+
+	if !slices.Contains(events, "error up 1 2024_01_02_000000_broken") {
+		t.Errorf("OnError was not called: %v", events)
+	}
+
+	if slices.Contains(events, "after_batch up 1") {
+		t.Errorf("AfterBatch should not be called for a failed batch")
+	}
+}
+
+func TestHookFiles(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	conn := m.driver.GetConnection()
+
+	writeMigration(t, filepath.Join(mgf, "hooks"), "before_migrate", "create table hook_log (event varchar(255));")
+	writeMigration(t, filepath.Join(mgf, "hooks"), "after_migrate", "insert into hook_log values ('migrated');")
+	writeMigration(t, filepath.Join(mgf, "hooks"), "after_rollback", "insert into hook_log values ('rolled back');")
+	writeMigration(t, mgf, "2024_01_01_000000_first", "-- UP --\n\n-- DOWN --")
+
+	if err := m.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	conn.QueryRow("select count(*) from hook_log").Scan(&count)
+
+	if count != 2 {
+		t.Errorf("Hook files did not run, got %d rows in hook_log", count)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	var buf bytes.Buffer
+	m.Logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	writeMigration(t, mgf, "2024_01_01_000000_first", "-- UP --\n\n-- DOWN --")
+	writeMigration(t, mgf, "2024_01_02_000000_broken", "-- UP --\nnot sql;\n-- DOWN --")
+	m.Migrate()
+
+	events := map[string]map[string]any{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var e map[string]any
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("Invalid log line %s", line)
+		}
+
+		events[e["msg"].(string)] = e
+	}
+
+	for _, msg := range []string{"batch started", "migration started", "migration finished", "migration failed"} {
+		if _, ok := events[msg]; !ok {
+			t.Errorf("Missing %s event", msg)
+		}
+	}
+
+	finished := events["migration finished"]
+	if finished["migration"] != "2024_01_01_000000_first" || finished["batch"] != float64(1) || finished["duration"] == nil {
+		t.Errorf("Incorrect migration finished event %v", finished)
+	}
+
+	if events["migration failed"]["error"] == nil {
+		t.Errorf("Migration failed event has no error")
+	}
+}
+
+type countingLock struct {
+	locks   int
+	unlocks int
+}
+
+func (l *countingLock) Lock() error {
+	l.locks++
+	return nil
+}
+
+func (l *countingLock) Unlock() error {
+	l.unlocks++
+	return nil
+}
+
+func TestNewWithDB(t *testing.T) {
+	d := t.TempDir()
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(d, "testdb.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	lock := &countingLock{}
+	fsys := fstest.MapFS{
+		"migrations/2024_01_01_000000_create_users.sql": {Data: []byte("-- UP --\ncreate table users (id int);\n-- DOWN --\ndrop table users;")},
+	}
+
+	m, err := NewWithDB(db, database.DriverSqlite,
+		WithFS(fsys),
+		WithPath("migrations"),
+		WithTable("schema_migrations"),
+		WithLock(lock),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	db.QueryRow("select count(*) from schema_migrations").Scan(&count)
+
+	if count != 1 {
+		t.Errorf("Migration was not logged in the custom table")
+	}
+
+	if err := db.QueryRow("select count(*) from users").Scan(&count); err != nil {
+		t.Errorf("Migration from the file system was not run: %s", err)
+	}
+
+	if lock.locks != 1 || lock.unlocks != 1 {
+		t.Errorf("Lock was not held while migrating: %d locks, %d unlocks", lock.locks, lock.unlocks)
+	}
+
+	if _, err := NewWithDB(db, "nope"); err == nil {
+		t.Errorf("Expected an error for an unknown driver")
+	}
+}
+
+func TestClose(t *testing.T) {
+	d := t.TempDir()
+	m := New(filepath.Join(d, "migrations"), database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	conn := m.driver.GetConnection()
+
+	m.CreateMigration("test_migration")
+	m.Migrate()
+
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if open := conn.Stats().OpenConnections; open != 0 {
+		t.Errorf("%d connections left open after Close", open)
+	}
+
+	if err := conn.Ping(); err == nil {
+		t.Errorf("Connection pool was not closed")
+	}
+}
+
+func TestCloseLeavesInjectedDBOpen(t *testing.T) {
+	d := t.TempDir()
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(d, "testdb.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m, _ := NewWithDB(db, database.DriverSqlite, WithPath(filepath.Join(d, "migrations")))
+
+	m.CreateMigration("test_migration")
+	m.Migrate()
+
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Ping(); err != nil {
+		t.Errorf("Close should not close a connection owned by the caller: %s", err)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if open := db.Stats().OpenConnections; open != 0 {
+		t.Errorf("%d connections left open", open)
+	}
+}
+
+func TestProtectedEnvironment(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	cfg := database.Config{Database: filepath.Join(d, "testdb.sqlite")}
+
+	m := New(mgf, database.DriverSqlite, cfg, WithEnvironment("production"))
+	conn := m.driver.GetConnection()
+
+	writeMigration(t, mgf, "2024_01_01_000000_first", "-- UP --\ncreate table first (id int);\n-- DOWN --\ndrop table first;")
+	m.Migrate()
+
+	if !m.Protected() {
+		t.Fatalf("production should be protected by default")
+	}
+
+	if err := m.Fresh(); !errors.Is(err, ErrProtectedEnvironment) {
+		t.Errorf("Fresh should refuse to run in production, got %v", err)
+	}
+
+	if err := m.Reset(); !errors.Is(err, ErrProtectedEnvironment) {
+		t.Errorf("Reset should refuse to run in production, got %v", err)
+	}
+
+	var count int
+	if err := conn.QueryRow("select count(*) from first").Scan(&count); err != nil {
+		t.Errorf("Tables were dropped in a protected environment")
+	}
+
+	m = New(mgf, database.DriverSqlite, cfg, WithEnvironment("production"), WithAllowDestructive())
+
+	if err := m.Reset(); err != nil {
+		t.Fatalf("Reset should run when destructive commands are allowed: %s", err)
+	}
+
+	if len(m.GetExistingMigrations()) != 0 {
+		t.Errorf("Reset did not roll back every migration")
+	}
+
+	m = New(mgf, database.DriverSqlite, cfg, WithEnvironment("staging"), WithProtectedEnvironments("staging"))
+
+	if err := m.Fresh(); !errors.Is(err, ErrProtectedEnvironment) {
+		t.Errorf("Fresh should refuse to run in a configured protected environment, got %v", err)
+	}
+}
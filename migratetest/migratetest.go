@@ -0,0 +1,168 @@
+// Package migratetest has helpers for tests of applications that use
+// migrate, and of the migrations themselves. Every database is a SQLite
+// file in a temporary directory, closed and deleted when the test ends.
+package migratetest
+
+import (
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javif89/migrate"
+	"github.com/javif89/migrate/database"
+)
+
+// A SQLite database with every migration in path applied
+func New(t testing.TB, path string, opts ...migrate.Option) *migrate.Migrations {
+	t.Helper()
+
+	m := Empty(t, path, opts...)
+
+	if err := m.Migrate(); err != nil && !errors.Is(err, migrate.ErrNoMigrationsToRun) {
+		t.Fatalf("migrating: %v", err)
+	}
+
+	return m
+}
+
+// A SQLite database without any migration applied. Logs go
+// to the test log unless opts set a logger.
+func Empty(t testing.TB, path string, opts ...migrate.Option) *migrate.Migrations {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(logWriter{t}, nil))
+
+	m, err := migrate.Open(path, database.DriverSqlite, database.Config{
+		Database: filepath.Join(t.TempDir(), "test.sqlite"),
+	}, append([]migrate.Option{migrate.WithLogger(logger)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { m.Close() })
+
+	return m
+}
+
+// A SQLite database with the migrations before name applied, e.g. to
+// seed data before running name with Apply
+func Before(t testing.TB, path string, name string, opts ...migrate.Option) *migrate.Migrations {
+	t.Helper()
+
+	m := Empty(t, path, opts...)
+	previous := ""
+
+	migrations, err := m.GetMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mg := range migrations {
+		if mg.Name() == name || strings.TrimSuffix(filepath.Base(mg.Path), ".sql") == name {
+			if previous != "" {
+				if err := m.MigrateTo(previous); err != nil {
+					t.Fatalf("running %s: %v", previous, err)
+				}
+			}
+
+			return m
+		}
+
+		previous = mg.Name()
+	}
+
+	t.Fatalf("migration %s not found", name)
+
+	return nil
+}
+
+// Run a single pending migration. Fails the test if other
+// migrations are pending before it.
+func Apply(t testing.TB, m *migrate.Migrations, name string) {
+	t.Helper()
+
+	p, err := m.Plan(migrate.Up, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Steps) != 1 {
+		t.Fatalf("%d migrations are pending up to %s, expected only it", len(p.Steps), name)
+	}
+
+	if err := p.Apply(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Roll back the last batch
+func Rollback(t testing.TB, m *migrate.Migrations) {
+	t.Helper()
+
+	if err := m.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Tables, columns, indexes and foreign keys in the database, to
+// compare with migrate.DiffSchemas
+func Snapshot(t testing.TB, m *migrate.Migrations) *database.Schema {
+	t.Helper()
+
+	s, err := m.Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+// Fail the test if the schemas are different. Tables in ignore are
+// left out, e.g. the migrations table.
+func AssertSchema(t testing.TB, expected *database.Schema, actual *database.Schema, ignore ...string) {
+	t.Helper()
+
+	for _, d := range migrate.DiffSchemas(expected, actual, ignore...) {
+		t.Error(d)
+	}
+}
+
+// Check every migration in path can be rolled back: each one runs on
+// its own, is rolled back, the schema has to be the same as before,
+// and then it runs again. Irreversible migrations are only applied.
+// Stops at the first migration that fails. See migrate.Verify.
+func AssertReversible(t testing.TB, path string, opts ...migrate.Option) {
+	t.Helper()
+
+	results, err := Empty(t, path, opts...).Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range results {
+		if r.Err != nil {
+			t.Errorf("%s: %v", r.Migration.Name(), r.Err)
+		}
+
+		for _, d := range r.Differences {
+			t.Errorf("%s: rolling back doesn't restore the schema: %s", r.Migration.Name(), d)
+		}
+
+		for _, d := range r.ReapplyDifferences {
+			t.Errorf("%s: running it again gives a different schema: %s", r.Migration.Name(), d)
+		}
+	}
+}
+
+// Sends logs to the test log
+type logWriter struct {
+	t testing.TB
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w.t.Log(strings.TrimSuffix(string(p), "\n"))
+
+	return len(p), nil
+}
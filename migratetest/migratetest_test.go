@@ -0,0 +1,88 @@
+package migratetest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeMigration(t *testing.T, dir string, name string, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, name+".sql"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, "2024_01_01_000000_create_users_table", "-- UP --\ncreate table users (id int, email text);\n-- DOWN --\ndrop table users;")
+
+	m := New(t, dir)
+
+	if _, err := m.DB().Exec("insert into users values (1, 'a@example.com')"); err != nil {
+		t.Errorf("The database should be migrated: %v", err)
+	}
+
+	if Snapshot(t, m).Table("users") == nil {
+		t.Errorf("Missing users table in the snapshot")
+	}
+}
+
+func TestBeforeAndApply(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, "2024_01_01_000000_create_users_table", "-- UP --\ncreate table users (id int, name text);\n-- DOWN --\ndrop table users;")
+	writeMigration(t, dir, "2024_01_02_000000_uppercase_names", "-- UP --\nupdate users set name = upper(name);\n-- DOWN --\n")
+
+	m := Before(t, dir, "2024_01_02_000000_uppercase_names")
+
+	m.DB().Exec("insert into users values (1, 'ada')")
+
+	Apply(t, m, "2024_01_02_000000_uppercase_names")
+
+	var name string
+	m.DB().QueryRow("select name from users").Scan(&name)
+
+	if name != "ADA" {
+		t.Errorf("Expected the migration to run on the seeded data, got %s", name)
+	}
+}
+
+func TestAssertReversible(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, "2024_01_01_000000_create_users_table", "-- UP --\ncreate table users (id int);\n-- DOWN --\ndrop table users;")
+	writeMigration(t, dir, "2024_01_02_000000_add_email", "-- UP --\nalter table users add column email text;\ncreate index users_email on users (email);\n-- DOWN --\ndrop index users_email;\nalter table users drop column email;")
+	writeMigration(t, dir, "2024_01_03_000000_backfill", "-- UP --\ninsert into users values (1, null);\n-- DOWN -- irreversible")
+
+	AssertReversible(t, dir)
+
+	// Forgetting the index in the down section
+	writeMigration(t, dir, "2024_01_04_000000_add_name", "-- UP --\nalter table users add column name text;\ncreate index users_name on users (name);\n-- DOWN --\n")
+
+	r := &recorder{TB: t}
+	AssertReversible(r, dir)
+
+	if len(r.errors) != 2 || !strings.Contains(r.errors[0], "2024_01_04_000000_add_name: rolling back doesn't restore the schema") {
+		t.Errorf("Expected the missing column and index to be reported, got %v", r.errors)
+	}
+}
+
+// Records errors instead of failing the test
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Error(args ...any) {
+	r.errors = append(r.errors, fmt.Sprint(args...))
+}
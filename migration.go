@@ -1,21 +1,58 @@
 package migrate
 
 import (
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"text/template"
+
+	"github.com/javif89/migrate/database"
 )
 
 type Migration struct {
 	Path string
+	// File system the migration lives in. Nil means the OS.
+	fsys fs.FS
+	// Driver we are running on, picks the -- IF <driver> -- sections
+	driver database.DriverName
+	// Template variables, see WithVars
+	vars map[string]string
 }
 
+// Variants for a driver (name.mysql.sql) share the name of the plain file
 func (m *Migration) Name() string {
-	return strings.Trim(filepath.Base(m.Path), ".sql")
+	base := filepath.Base(m.Path)
+
+	if d := m.Dialect(); d != "" {
+		base = strings.TrimSuffix(base, "."+string(d)+".sql") + ".sql"
+	}
+
+	return strings.Trim(base, ".sql")
+}
+
+// Driver the file is a variant for, e.g. mysql for
+// name.mysql.sql. Empty for plain files.
+func (m *Migration) Dialect() database.DriverName {
+	ext := filepath.Ext(strings.TrimSuffix(filepath.Base(m.Path), ".sql"))
+
+	if ext == "" {
+		return ""
+	}
+
+	d := database.DriverName(ext[1:])
+
+	if !slices.Contains(database.Drivers(), d) {
+		return ""
+	}
+
+	return d
 }
 
 func (m *Migration) GetContent() (string, error) {
-	content, err := os.ReadFile(m.Path)
+	content, err := readFile(m.fsys, m.Path)
 
 	if err != nil {
 		return "", err
@@ -24,9 +61,36 @@ func (m *Migration) GetContent() (string, error) {
 	return string(content), nil
 }
 
-func (m *Migration) GetUpQuery() string {
+// Contents of the file with the template variables filled in.
+// Using a variable that isn't defined is an error.
+func (m *Migration) Render() (string, error) {
 	c, err := m.GetContent()
 
+	if err != nil {
+		return "", err
+	}
+
+	vars := m.vars
+	if vars == nil {
+		vars = map[string]string{}
+	}
+
+	t, err := template.New(m.Name()).Option("missingkey=error").Funcs(templateFuncs).Parse(c)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", m.Name(), err)
+	}
+
+	var out strings.Builder
+	if err := t.Execute(&out, vars); err != nil {
+		return "", fmt.Errorf("%s: %w", m.Name(), err)
+	}
+
+	return out.String(), nil
+}
+
+func (m *Migration) GetUpQuery() string {
+	c, err := m.Render()
+
 	if err != nil {
 		return ""
 	}
@@ -34,6 +98,7 @@ func (m *Migration) GetUpQuery() string {
 	parts := strings.Split(c, "-- DOWN --")
 
 	q := strings.Replace(parts[0], "-- UP --\n", "", -1)
+	q = driverSections(q, m.driver)
 	q = strings.Trim(q, "\n")
 	q = strings.TrimSpace(q)
 
@@ -41,7 +106,7 @@ func (m *Migration) GetUpQuery() string {
 }
 
 func (m *Migration) GetDownQuery() string {
-	c, err := m.GetContent()
+	c, err := m.Render()
 
 	if err != nil {
 		return ""
@@ -49,9 +114,83 @@ func (m *Migration) GetDownQuery() string {
 
 	parts := strings.Split(c, "-- DOWN --")
 
+	if len(parts) < 2 || isIrreversible(parts[1]) {
+		return ""
+	}
+
 	q := strings.Replace(parts[1], "-- DOWN --\n", "", -1)
+	q = driverSections(q, m.driver)
 	q = strings.Trim(q, "\n")
 	q = strings.TrimSpace(q)
 
 	return q
-}
\ No newline at end of file
+}
+
+// Whether the migration is marked as impossible to roll back with
+//
+//	-- DOWN -- irreversible
+func (m *Migration) Irreversible() bool {
+	c, err := m.GetContent()
+
+	if err != nil {
+		return false
+	}
+
+	parts := strings.Split(c, "-- DOWN --")
+
+	return len(parts) > 1 && isIrreversible(parts[1])
+}
+
+// The marker goes on the same line as -- DOWN --
+func isIrreversible(down string) bool {
+	line, _, _ := strings.Cut(down, "\n")
+
+	return strings.EqualFold(strings.TrimSpace(line), "irreversible")
+}
+
+// Keep only the sections meant for the driver we are running on:
+//
+//	-- IF sqlite --
+//	id integer primary key autoincrement
+//	-- IF mysql --
+//	id bigint auto_increment primary key
+//	-- END IF --
+func driverSections(sql string, driver database.DriverName) string {
+	lines := []string{}
+	keep := true
+
+	for _, line := range strings.Split(sql, "\n") {
+		marker := strings.TrimSpace(line)
+
+		if strings.HasPrefix(marker, "-- IF ") && strings.HasSuffix(marker, " --") {
+			drivers := splitList(strings.TrimSuffix(strings.TrimPrefix(marker, "-- IF "), " --"))
+			keep = slices.Contains(drivers, string(driver))
+
+			continue
+		}
+
+		if marker == "-- END IF --" {
+			keep = true
+			continue
+		}
+
+		if keep {
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Functions available in migration templates
+var templateFuncs = template.FuncMap{
+	// Value of an environment variable. Unset variables are an error.
+	"env": func(name string) (string, error) {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+
+		return v, nil
+	},
+}
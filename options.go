@@ -0,0 +1,149 @@
+package migrate
+
+import (
+	"io/fs"
+	"log/slog"
+
+	"github.com/javif89/migrate/database"
+)
+
+// Configures a Migrations instance. Pass them to New or NewWithDB.
+type Option func(*Migrations)
+
+// Read migrations from this folder. When used with WithFS the
+// path is relative to the root of the file system.
+func WithPath(path string) Option {
+	return func(m *Migrations) {
+		m.path = path
+	}
+}
+
+// Keep track of migrations in a table other than "migrations"
+func WithTable(table string) Option {
+	return func(m *Migrations) {
+		m.table = table
+	}
+}
+
+// Read migrations, hook files and templates from a file system
+// such as an embed.FS instead of the OS. Creating migrations still
+// writes to disk.
+func WithFS(fsys fs.FS) Option {
+	return func(m *Migrations) {
+		m.fsys = fsys
+	}
+}
+
+// Also read migrations from these folders, e.g. one per module.
+// They run in a single sequence with the ones in the main path.
+func WithModulePaths(paths ...string) Option {
+	return func(m *Migrations) {
+		m.modulePaths = paths
+	}
+}
+
+// Hold a lock while migrating so two processes can't
+// migrate the same database at the same time
+func WithLock(l Locker) Option {
+	return func(m *Migrations) {
+		m.lock = l
+	}
+}
+
+// Limit what Fresh drops, e.g. to leave tables owned by
+// other services in a shared database alone
+func WithWipeOptions(opts database.WipeOptions) Option {
+	return func(m *Migrations) {
+		m.wipe = opts
+	}
+}
+
+// Name of the environment we are running in, e.g. production
+func WithEnvironment(env string) Option {
+	return func(m *Migrations) {
+		m.environment = env
+	}
+}
+
+// Environments where Fresh and Reset refuse to run.
+// Defaults to DefaultProtectedEnvironments.
+func WithProtectedEnvironments(envs ...string) Option {
+	return func(m *Migrations) {
+		m.protectedEnvs = envs
+	}
+}
+
+// Let Fresh and Reset run in protected environments
+func WithAllowDestructive() Option {
+	return func(m *Migrations) {
+		m.allowDestructive = true
+	}
+}
+
+// Store each migration's down SQL in the migrations table when it's
+// applied, so it can be rolled back even if the file is deleted
+func WithStoreDownSQL() Option {
+	return func(m *Migrations) {
+		m.storeDown = true
+	}
+}
+
+// Variables migrations can use as templates, e.g. {{ .Schema }}
+func WithVars(vars map[string]string) Option {
+	return func(m *Migrations) {
+		m.vars = vars
+	}
+}
+
+// Rules used by Lint instead of DefaultLintRules. Append to
+// DefaultLintRules to add your own.
+func WithLintRules(rules ...LintRule) Option {
+	return func(m *Migrations) {
+		m.lintRules = rules
+	}
+}
+
+// Lint rules to skip for every migration, by name
+func WithLintIgnore(rules ...string) Option {
+	return func(m *Migrations) {
+		m.lintIgnore = append(m.lintIgnore, rules...)
+	}
+}
+
+// Only run migrations tagged with one of these tags
+// (-- migrate:tag data). Untagged migrations are left alone.
+func WithTags(tags ...string) Option {
+	return func(m *Migrations) {
+		m.tags = tags
+	}
+}
+
+func WithLogger(l *slog.Logger) Option {
+	return func(m *Migrations) {
+		m.Logger = l
+	}
+}
+
+func WithHooks(h Hooks) Option {
+	return func(m *Migrations) {
+		m.Hooks = h
+	}
+}
+
+func WithHooksPath(path string) Option {
+	return func(m *Migrations) {
+		m.HooksPath = path
+	}
+}
+
+func WithTemplatesPath(path string) Option {
+	return func(m *Migrations) {
+		m.TemplatesPath = path
+	}
+}
+
+func WithOutOfOrder(p OutOfOrderPolicy) Option {
+	return func(m *Migrations) {
+		m.OutOfOrder = p
+	}
+}
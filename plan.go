@@ -0,0 +1,589 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/javif89/migrate/database"
+)
+
+var ErrStalePlan error = errors.New("plan is out of date, the database changed since it was computed")
+var ErrUnknownTarget error = errors.New("unknown target migration")
+var ErrIrreversible error = errors.New("irreversible migrations can't be rolled back")
+
+// Returned when rolling back migrations marked -- DOWN -- irreversible.
+// Lists the offending migrations.
+type IrreversibleError struct {
+	Migrations []string
+}
+
+func (e *IrreversibleError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrIrreversible, strings.Join(e.Migrations, ", "))
+}
+
+func (e *IrreversibleError) Unwrap() error {
+	return ErrIrreversible
+}
+
+// Statements MySQL commits implicitly, so a failure halfway
+// through a migration can't be rolled back
+var ddlPattern = regexp.MustCompile(`(?im)^\s*(create|alter|drop|rename|truncate)\s`)
+
+// A single migration a Plan will run
+type Step struct {
+	Migration Migration
+	Direction Direction
+	// SQL that will be run
+	SQL string
+	// Batch the migration gets when migrating, or the one
+	// it belongs to when rolling back
+	Batch int
+	// Settings declared in the file, e.g. -- migrate:no-transaction
+	Directives Directives
+	// Checksum of a repeatable migration
+	Checksum string
+	// Marked -- DOWN -- irreversible
+	Irreversible bool
+	Warnings     []string
+}
+
+// The migrations that would run, in order, computed ahead of time
+// so they can be reviewed before being applied
+type Plan struct {
+	Direction Direction
+	Steps     []Step
+	m         *Migrations
+}
+
+// Compute what Migrate (Up) or Rollback (Down) would run without running it.
+//
+// When migrating, target is the last migration to run. When rolling back,
+// every migration applied after target is rolled back. An empty target
+// means every pending migration for Up and the last batch for Down.
+// Nothing is written to the database, not even the migrations table.
+func (m *Migrations) Plan(dir Direction, target string) (*Plan, error) {
+	if dir == Down {
+		return m.planDown(target)
+	}
+
+	return m.planUp(target)
+}
+
+func (m *Migrations) planUp(target string) (*Plan, error) {
+	// A folder with only repeatable migrations is fine
+	unexecuted, err := m.GetUnexecutedMigrations()
+	noVersioned := errors.Is(err, ErrNoMigrations)
+
+	if err != nil && !noVersioned {
+		return nil, err
+	}
+
+	// Leave out migrations meant for other environments or tags
+	pending := []Migration{}
+	directives := map[string]Directives{}
+
+	for _, mg := range unexecuted {
+		d, err := mg.Directives()
+		if err != nil {
+			return nil, err
+		}
+
+		if !m.selects(d) {
+			m.logger().Debug("migration skipped", "migration", mg.Name(), "directives", d.String())
+			continue
+		}
+
+		// Undefined template variables fail the plan, not the run
+		if _, err := mg.Render(); err != nil {
+			return nil, err
+		}
+
+		pending = append(pending, mg)
+		directives[mg.Name()] = d
+	}
+
+	if target != "" {
+		i := indexOf(pending, target)
+
+		if i == -1 {
+			return nil, fmt.Errorf("%w: %s is not pending", ErrUnknownTarget, target)
+		}
+
+		pending = pending[:i+1]
+	}
+
+	records, err := m.recordsByName()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Plan{Direction: Up, m: m}
+	batch := m.nextBatch()
+	ooo := m.outOfOrder(pending)
+
+	for i, mg := range pending {
+		s := Step{
+			Migration:  mg,
+			Direction:  Up,
+			SQL:        mg.GetUpQuery(),
+			Batch:      batch,
+			Directives: directives[mg.Name()],
+		}
+
+		// Requirements have to be applied already or run earlier in the plan
+		for _, req := range s.Directives.Requires {
+			if _, ok := records[recordName(req)]; !ok && indexOf(pending[:i], req) == -1 {
+				return nil, fmt.Errorf("%w: %s requires %s, which is not applied", ErrUnmetRequirement, mg.Name(), req)
+			}
+		}
+
+		if slices.ContainsFunc(ooo, func(o Migration) bool { return o.Name() == mg.Name() }) {
+			s.Warnings = append(s.Warnings, "out of order: older than the last applied migration")
+		}
+
+		if mg.Irreversible() {
+			s.Warnings = append(s.Warnings, "irreversible: it can't be rolled back")
+		} else if mg.GetDownQuery() == "" {
+			s.Warnings = append(s.Warnings, "missing down section: rolling it back won't undo anything")
+		}
+
+		p.Steps = append(p.Steps, m.withCommonWarnings(s))
+	}
+
+	// Repeatable migrations run after the versioned ones,
+	// unless we were asked to stop at a target
+	if target == "" {
+		repeatables, err := m.planRepeatables(records)
+		if err != nil {
+			return nil, err
+		}
+
+		p.Steps = append(p.Steps, repeatables...)
+	}
+
+	if p.Empty() && noVersioned {
+		return nil, ErrNoMigrations
+	}
+
+	return p, nil
+}
+
+func (m *Migrations) planDown(target string) (*Plan, error) {
+	files, err := m.migrationsOnDisk()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.records()
+	if err != nil {
+		return nil, err
+	}
+
+	onDisk := map[string]Migration{}
+	for _, mg := range files {
+		onDisk[mg.Name()] = mg
+	}
+
+	// Applied migrations, last applied first. This includes the ones whose
+	// file is gone so their rows don't stay behind forever.
+	migrations := []Migration{}
+	records := map[string]record{}
+
+	slices.Reverse(applied)
+
+	for _, r := range applied {
+		// Repeatable migrations are never rolled back
+		if isRepeatable(r.Name) {
+			continue
+		}
+
+		mg, ok := onDisk[r.Name]
+		if !ok {
+			mg = Migration{Path: r.Name}
+		}
+
+		migrations = append(migrations, mg)
+		records[r.Name] = r
+	}
+
+	// Everything before the target gets rolled back
+	last := len(migrations)
+
+	if target != "" {
+		last = indexOf(migrations, target)
+
+		if last == -1 {
+			return nil, fmt.Errorf("%w: %s has not been applied", ErrUnknownTarget, target)
+		}
+	}
+
+	directives := map[string]Directives{}
+
+	for _, mg := range migrations {
+		if _, ok := onDisk[mg.Name()]; !ok {
+			continue
+		}
+
+		d, err := mg.Directives()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := mg.Render(); err != nil {
+			return nil, err
+		}
+
+		directives[mg.Name()] = d
+	}
+
+	batch := m.currentBatch()
+	p := &Plan{Direction: Down, m: m}
+	missing := []string{}
+
+	for _, mg := range migrations[:last] {
+		r := records[mg.Name()]
+
+		// Without a target only the last batch is rolled back
+		if target == "" && r.Batch != batch {
+			continue
+		}
+
+		if !m.selects(directives[mg.Name()]) {
+			continue
+		}
+
+		s := Step{
+			Migration:  mg,
+			Direction:  Down,
+			SQL:        mg.GetDownQuery(),
+			Batch:      r.Batch,
+			Directives: directives[mg.Name()],
+		}
+
+		if _, ok := onDisk[mg.Name()]; !ok {
+			if !r.Down.Valid {
+				missing = append(missing, mg.Name())
+				continue
+			}
+
+			s.SQL = r.Down.String
+			s.Warnings = append(s.Warnings, "file is missing: using the down SQL stored when it was applied")
+		}
+
+		if mg.Irreversible() {
+			s.Irreversible = true
+			s.Warnings = append(s.Warnings, "irreversible: rolling back will fail")
+		} else if s.SQL == "" {
+			s.Warnings = append(s.Warnings, "missing down section: nothing will be undone")
+		}
+
+		p.Steps = append(p.Steps, m.withCommonWarnings(s))
+	}
+
+	if len(missing) > 0 {
+		return nil, &MissingMigrationError{Migrations: missing}
+	}
+
+	// Migrations that stay applied can't lose their requirements
+	for _, mg := range migrations {
+		if slices.ContainsFunc(p.Steps, func(s Step) bool { return s.Migration.Name() == mg.Name() }) {
+			continue
+		}
+
+		for _, req := range directives[mg.Name()].Requires {
+			if i := indexOf(p.stepMigrations(), req); i != -1 {
+				return nil, fmt.Errorf("%w: %s requires %s, roll it back first", ErrUnmetRequirement, mg.Name(), p.Steps[i].Migration.Name())
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// Position of the migration called name, either by its
+// name or its file name without the extension
+func indexOf(migrations []Migration, name string) int {
+	return slices.IndexFunc(migrations, func(mg Migration) bool {
+		return mg.Name() == name || strings.TrimSuffix(filepath.Base(mg.Path), ".sql") == name
+	})
+}
+
+// Name a migration is recorded under in the migrations table,
+// given its name or file name
+func recordName(name string) string {
+	mg := Migration{Path: strings.TrimSuffix(name, ".sql") + ".sql"}
+
+	return mg.Name()
+}
+
+// Warnings that apply in both directions
+func (m *Migrations) withCommonWarnings(s Step) Step {
+	if m.dialect == database.DriverMysql && ddlPattern.MatchString(s.SQL) {
+		s.Warnings = append(s.Warnings, "non-transactional: MySQL commits DDL implicitly, a failure can leave it half applied")
+	}
+
+	return s
+}
+
+func (p *Plan) Empty() bool {
+	return len(p.Steps) == 0
+}
+
+// Run the plan. Fails with ErrStalePlan if the database
+// changed since the plan was computed.
+func (p *Plan) Apply() error {
+	return p.m.withLock(p.apply)
+}
+
+func (p *Plan) apply() error {
+	m := p.m
+
+	if p.Empty() {
+		return ErrNoMigrationsToRun
+	}
+
+	if err := m.prepareTable(p.Steps); err != nil {
+		return err
+	}
+
+	if err := p.checkStale(); err != nil {
+		return err
+	}
+
+	// Refuse before touching anything, so the batch
+	// isn't left half rolled back
+	if irreversible := p.irreversible(); len(irreversible) > 0 {
+		return &IrreversibleError{Migrations: irreversible}
+	}
+
+	if p.Direction == Up {
+		if err := m.checkOutOfOrder(p.stepMigrations()); err != nil {
+			return err
+		}
+	}
+
+	if err := m.runHookFile("before", p.Direction); err != nil {
+		return err
+	}
+
+	// Steps are grouped by batch, which only matters when
+	// rolling back to a target across several batches
+	for _, steps := range p.batches() {
+		if err := m.runBatch(steps); err != nil {
+			return err
+		}
+	}
+
+	return m.runHookFile("after", p.Direction)
+}
+
+// Make sure the database is still in the state the plan expects
+func (p *Plan) checkStale() error {
+	applied, err := p.m.recordsByName()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range p.Steps {
+		r, ok := applied[s.Migration.Name()]
+
+		if s.Migration.Repeatable() {
+			ok = ok && r.Checksum.String == s.Checksum
+		}
+
+		if (p.Direction == Up && ok) || (p.Direction == Down && !ok) {
+			return fmt.Errorf("%w: %s", ErrStalePlan, s.Migration.Name())
+		}
+	}
+
+	if p.Direction == Up && !p.Steps[0].Migration.Repeatable() && p.m.nextBatch() != p.Steps[0].Batch {
+		return ErrStalePlan
+	}
+
+	return nil
+}
+
+func (p *Plan) stepMigrations() []Migration {
+	migrations := []Migration{}
+
+	for _, s := range p.Steps {
+		migrations = append(migrations, s.Migration)
+	}
+
+	return migrations
+}
+
+// Names of the steps that can't be rolled back
+func (p *Plan) irreversible() []string {
+	names := []string{}
+
+	for _, s := range p.Steps {
+		if s.Direction == Down && s.Irreversible {
+			names = append(names, s.Migration.Name())
+		}
+	}
+
+	return names
+}
+
+func (p *Plan) batches() [][]Step {
+	batches := [][]Step{}
+
+	for i, s := range p.Steps {
+		if i == 0 || s.Batch != p.Steps[i-1].Batch {
+			batches = append(batches, []Step{})
+		}
+
+		batches[len(batches)-1] = append(batches[len(batches)-1], s)
+	}
+
+	return batches
+}
+
+// Human readable version of the plan
+func (p *Plan) String() string {
+	if p.Empty() {
+		return "Nothing to run\n"
+	}
+
+	var b strings.Builder
+
+	verb := "Migrate"
+	if p.Direction == Down {
+		verb = "Roll back"
+	}
+
+	fmt.Fprintf(&b, "%s %d migration(s)\n", verb, len(p.Steps))
+
+	for _, s := range p.Steps {
+		details := fmt.Sprintf("batch %d", s.Batch)
+		if s.Migration.Repeatable() {
+			details = "repeatable"
+		}
+
+		if d := s.Directives.String(); d != "" {
+			details += ", " + d
+		}
+
+		fmt.Fprintf(&b, "\n%s (%s)\n", s.Migration.Name(), details)
+
+		for _, w := range s.Warnings {
+			fmt.Fprintf(&b, "  ! %s\n", w)
+		}
+
+		for _, line := range strings.Split(s.SQL, "\n") {
+			fmt.Fprintf(&b, "    %s\n", line)
+		}
+	}
+
+	return b.String()
+}
+
+// Run a batch of steps along with the lifecycle hooks
+func (m *Migrations) runBatch(steps []Step) error {
+	dir, batch := steps[0].Direction, steps[0].Batch
+
+	m.logger().Info("batch started", "direction", dir, "batch", batch, "migrations", len(steps))
+	m.Hooks.beforeBatch(Event{Direction: dir, Batch: batch})
+	start := time.Now()
+
+	for _, s := range steps {
+		if err := m.runStep(s); err != nil {
+			return err
+		}
+	}
+
+	m.logger().Info("batch finished", "direction", dir, "batch", batch, "duration", time.Since(start))
+	m.Hooks.afterBatch(Event{Direction: dir, Batch: batch, Duration: time.Since(start)})
+
+	return nil
+}
+
+func (m *Migrations) runStep(s Step) error {
+	mg := s.Migration
+	e := Event{Migration: &mg, Direction: s.Direction, Batch: s.Batch}
+
+	logger := m.logger().With("migration", mg.Name(), "direction", s.Direction, "batch", s.Batch)
+
+	logger.Debug("migration started")
+	m.Hooks.beforeMigration(e)
+
+	start := time.Now()
+	err := m.execStep(s)
+	e.Duration = time.Since(start)
+
+	if err != nil {
+		e.Err = err
+		logger.Error("migration failed", "duration", e.Duration, "error", err)
+		m.Hooks.onError(e)
+
+		return fmt.Errorf("failed migrating %s: %w", mg.Name(), err)
+	}
+
+	logger.Info("migration finished", "duration", e.Duration)
+	m.Hooks.afterMigration(e)
+
+	return nil
+}
+
+// Anything we can run queries on, a connection or a transaction
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Run the step and record it in the migrations table. Both happen in
+// one transaction unless the migration has -- migrate:no-transaction
+func (m *Migrations) execStep(s Step) error {
+	ctx := context.Background()
+
+	if s.Directives.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Directives.Timeout)
+		defer cancel()
+	}
+
+	db := m.driver.GetConnection()
+
+	if s.Directives.NoTransaction {
+		return m.execAndRecord(ctx, db, s)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := m.execAndRecord(ctx, tx, s); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *Migrations) execAndRecord(ctx context.Context, ex execer, s Step) error {
+	// An empty section means there is nothing to do, and
+	// some drivers fail on empty queries
+	if strings.TrimSpace(s.SQL) != "" {
+		if _, err := ex.ExecContext(ctx, s.SQL); err != nil {
+			return err
+		}
+	}
+
+	if s.Direction == Up && s.Migration.Repeatable() {
+		return m.logRepeatable(ctx, ex, s)
+	}
+
+	if s.Direction == Up {
+		return m.logMigration(ctx, ex, s.Migration, s.Batch)
+	}
+
+	return m.removeMigration(ctx, ex, s.Migration)
+}
@@ -0,0 +1,207 @@
+package migrate
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javif89/migrate/database"
+)
+
+func TestPlan(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	writeMigration(t, mgf, "2024_01_02_000000_users", "-- UP --\ncreate table users (id int);\n-- DOWN --\ndrop table users;")
+	m.Migrate()
+
+	writeMigration(t, mgf, "2024_01_01_000000_older", "-- UP --\ncreate table older (id int);\n-- DOWN --\n")
+	writeMigration(t, mgf, "2024_01_03_000000_posts", "-- UP --\ncreate table posts (id int);\n-- DOWN --\ndrop table posts;")
+	writeMigration(t, mgf, "2024_01_04_000000_tags", "-- UP --\ncreate table tags (id int);\n-- DOWN --\ndrop table tags;")
+
+	p, err := m.Plan(Up, "2024_01_03_000000_posts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Steps) != 2 {
+		t.Fatalf("Expected 2 steps up to the target, got %d", len(p.Steps))
+	}
+
+	older := p.Steps[0]
+
+	if older.Batch != 2 || !strings.Contains(older.SQL, "create table older") {
+		t.Errorf("Incorrect step: %+v", older)
+	}
+
+	if len(older.Warnings) != 2 {
+		t.Errorf("Expected out of order and missing down warnings, got %v", older.Warnings)
+	}
+
+	if len(p.Steps[1].Warnings) != 0 {
+		t.Errorf("Unexpected warnings: %v", p.Steps[1].Warnings)
+	}
+
+	if len(m.GetExistingMigrations()) != 1 {
+		t.Fatalf("Planning should not run anything")
+	}
+
+	if err := p.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.GetExistingMigrations()) != 3 {
+		t.Errorf("Applying the plan should run its steps only")
+	}
+
+	// The database changed since the plan was computed
+	if err := p.Apply(); !errors.Is(err, ErrStalePlan) {
+		t.Errorf("Expected a stale plan error, got %v", err)
+	}
+
+	if _, err := m.Plan(Up, "2024_01_01_000000_older"); !errors.Is(err, ErrUnknownTarget) {
+		t.Errorf("Expected an unknown target error, got %v", err)
+	}
+}
+
+func TestPlanDown(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	writeMigration(t, mgf, "2024_01_01_000000_users", "-- UP --\ncreate table users (id int);\n-- DOWN --\ndrop table users;")
+	m.Migrate()
+	writeMigration(t, mgf, "2024_01_02_000000_posts", "-- UP --\ncreate table posts (id int);\n-- DOWN --\ndrop table posts;")
+	m.Migrate()
+	writeMigration(t, mgf, "2024_01_03_000000_tags", "-- UP --\ncreate table tags (id int);\n-- DOWN --\ndrop table tags;")
+	m.Migrate()
+
+	p, err := m.Plan(Down, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Steps) != 1 || p.Steps[0].Batch != 3 || p.Steps[0].SQL != "drop table tags;" {
+		t.Fatalf("Expected to roll back the last batch only: %+v", p.Steps)
+	}
+
+	p, err = m.Plan(Down, "2024_01_01_000000_users")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Steps) != 2 || !strings.Contains(p.Steps[0].Migration.Name(), "tag") {
+		t.Fatalf("Expected to roll back everything after the target, newest first: %+v", p.Steps)
+	}
+
+	batches := []int{}
+	m.Hooks.AfterBatch = func(e Event) {
+		batches = append(batches, e.Batch)
+	}
+
+	if err := p.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(batches) != 2 || batches[0] != 3 || batches[1] != 2 {
+		t.Errorf("Expected each batch to be rolled back separately, got %v", batches)
+	}
+
+	if existing := m.GetExistingMigrations(); len(existing) != 1 {
+		t.Errorf("Incorrect migrations left: %v", existing)
+	}
+}
+
+func TestPlanDoesNotWrite(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	}, WithStoreDownSQL())
+
+	writeMigration(t, mgf, "2024_01_01_000000_users", "-- UP --\ncreate table users (id int);\n-- DOWN --\ndrop table users;")
+	writeMigration(t, mgf, "R__users_view", "-- UP --\ncreate view if not exists users_view as select id from users;\n-- DOWN --\n")
+
+	for _, dir := range []Direction{Up, Down} {
+		if _, err := m.Plan(dir, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if m.hasTable() {
+		t.Fatalf("Planning should not create the migrations table")
+	}
+
+	if err := m.driver.CreateMigrationsTable(m.table); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := m.Plan(Up, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.hasColumn(downColumn) || m.hasColumn(checksumColumn) {
+		t.Fatalf("Planning should not add columns to the migrations table")
+	}
+
+	if err := p.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !m.hasColumn(downColumn) || !m.hasColumn(checksumColumn) {
+		t.Errorf("Applying the plan should add the columns it needs")
+	}
+}
+
+func TestIrreversibleRollback(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	writeMigration(t, mgf, "2024_01_01_000000_users", "-- UP --\ncreate table users (id int);\n-- DOWN --\ndrop table users;")
+	writeMigration(t, mgf, "2024_01_02_000000_backfill_users", "-- UP --\ninsert into users values (1);\n-- DOWN -- irreversible\n")
+	writeMigration(t, mgf, "2024_01_03_000000_noop", "-- UP --\nselect 1;\n-- DOWN --\n")
+
+	p, _ := m.Plan(Up, "")
+
+	if len(p.Steps[1].Warnings) != 1 || !strings.Contains(p.Steps[1].Warnings[0], "irreversible") {
+		t.Errorf("Irreversible migration should be flagged: %v", p.Steps[1].Warnings)
+	}
+
+	if len(p.Steps[2].Warnings) != 1 || !strings.Contains(p.Steps[2].Warnings[0], "missing down") {
+		t.Errorf("Empty down section should be flagged: %v", p.Steps[2].Warnings)
+	}
+
+	if p.Steps[1].Migration.GetDownQuery() != "" {
+		t.Errorf("The marker should not be part of the down SQL")
+	}
+
+	if err := p.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := m.Rollback()
+
+	var irrErr *IrreversibleError
+	if !errors.As(err, &irrErr) || !errors.Is(err, ErrIrreversible) {
+		t.Fatalf("Expected an irreversible error, got %v", err)
+	}
+
+	if len(irrErr.Migrations) != 1 || !strings.Contains(irrErr.Migrations[0], "backfill_user") {
+		t.Errorf("Irreversible error does not list the migration: %v", irrErr.Migrations)
+	}
+
+	// Nothing in the batch was touched, not even the migrations after it
+	if len(m.GetExistingMigrations()) != 3 {
+		t.Errorf("Rollback should stop before touching the batch")
+	}
+}
@@ -0,0 +1,95 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Repeatable migrations are named R__<name>.sql. Instead of running once,
+// they run again whenever they change, e.g. to recreate views.
+var repeatablePrefix = "R__"
+
+func (m *Migration) Repeatable() bool {
+	return isRepeatable(filepath.Base(m.Path))
+}
+
+func isRepeatable(name string) bool {
+	return strings.HasPrefix(name, repeatablePrefix)
+}
+
+// Checksum of the rendered file contents, used to tell when a
+// repeatable migration has to run again
+func (m *Migration) Checksum() (string, error) {
+	c, err := m.Render()
+
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(c))
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Get the repeatable migrations, in order
+func (m *Migrations) GetRepeatableMigrations() ([]Migration, error) {
+	return m.migrationFiles(true)
+}
+
+// Steps for the repeatable migrations that are new or changed since
+// they last ran. They aren't part of a batch, so they use batch 0 and
+// are never rolled back.
+func (m *Migrations) planRepeatables(records map[string]record) ([]Step, error) {
+	repeatables, err := m.GetRepeatableMigrations()
+	if err != nil || len(repeatables) == 0 {
+		return nil, err
+	}
+
+	steps := []Step{}
+
+	for _, mg := range repeatables {
+		d, err := mg.Directives()
+		if err != nil {
+			return nil, err
+		}
+
+		if !m.selects(d) {
+			continue
+		}
+
+		sum, err := mg.Checksum()
+		if err != nil {
+			return nil, err
+		}
+
+		if r, ok := records[mg.Name()]; ok && r.Checksum.String == sum {
+			continue
+		}
+
+		steps = append(steps, m.withCommonWarnings(Step{
+			Migration:  mg,
+			Direction:  Up,
+			SQL:        mg.GetUpQuery(),
+			Directives: d,
+			Checksum:   sum,
+		}))
+	}
+
+	return steps, nil
+}
+
+// Replace the row of a repeatable migration with its new checksum
+func (m *Migrations) logRepeatable(ctx context.Context, ex execer, s Step) error {
+	if err := m.removeMigration(ctx, ex, s.Migration); err != nil {
+		return err
+	}
+
+	q := fmt.Sprintf("insert into %s (migration, batch, %s) values (%s, 0, %s)", m.table, checksumColumn, m.placeholder(1), m.placeholder(2))
+	_, err := ex.ExecContext(ctx, q, s.Migration.Name(), s.Checksum)
+
+	return err
+}
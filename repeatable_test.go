@@ -0,0 +1,103 @@
+package migrate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javif89/migrate/database"
+)
+
+func TestRepeatableMigrations(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	conn := m.driver.GetConnection()
+
+	writeMigration(t, mgf, "R__active_users_view", "-- UP --\ndrop view if exists active_users;\ncreate view active_users as select id from users;\n-- DOWN --\n")
+	writeMigration(t, mgf, "2024_01_01_000000_create_users_table", "-- UP --\ncreate table users (id int);\n-- DOWN --\ndrop table users;")
+
+	p, err := m.Plan(Up, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The view needs the table, so it runs last
+	if len(p.Steps) != 2 || !p.Steps[1].Migration.Repeatable() {
+		t.Fatalf("Repeatable migrations should run after versioned ones: %+v", p.Steps)
+	}
+
+	if err := p.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing changed
+	if err := m.Migrate(); !errors.Is(err, ErrNoMigrationsToRun) {
+		t.Errorf("Unchanged repeatable migrations should not run again, got %v", err)
+	}
+
+	writeMigration(t, mgf, "R__active_users_view", "-- UP --\ndrop view if exists active_users;\ncreate view active_users as select id, 1 as active from users;\n-- DOWN --\n")
+
+	statuses, _ := m.Status()
+
+	if s := statuses[len(statuses)-1]; !s.Repeatable || !s.Outdated {
+		t.Errorf("Changed repeatable migration should be outdated: %+v", s)
+	}
+
+	if err := m.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var sql string
+	conn.QueryRow("select sql from sqlite_master where name = 'active_users'").Scan(&sql)
+
+	if !strings.Contains(sql, "active") {
+		t.Errorf("Changed repeatable migration was not applied: %s", sql)
+	}
+
+	var rows int
+	conn.QueryRow("select count(*) from migrations where migration = 'R__active_users_view'").Scan(&rows)
+
+	if rows != 1 {
+		t.Errorf("Repeatable migrations should have a single row, got %d", rows)
+	}
+
+	// They are never rolled back, and don't make versioned ones out of order
+	if err := m.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	if existing := m.GetExistingMigrations(); len(existing) != 1 || existing[0] != "R__active_users_view" {
+		t.Errorf("Only the repeatable migration should be left: %v", existing)
+	}
+
+	if ooo, _ := m.GetOutOfOrderMigrations(); len(ooo) != 0 {
+		t.Errorf("Repeatable migrations should not count for out of order checks: %v", ooo)
+	}
+}
+
+func TestCreateRepeatableMigrationKeepsExistingFile(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	sql := "-- UP --\ncreate view v as select 1;\n-- DOWN --\n"
+	writeMigration(t, mgf, "R__views", sql)
+
+	if _, err := m.CreateMigrationFrom("views", CreateOptions{Repeatable: true}); !errors.Is(err, ErrMigrationExists) {
+		t.Errorf("Expected ErrMigrationExists, got %v", err)
+	}
+
+	content, _ := os.ReadFile(filepath.Join(mgf, "R__views.sql"))
+
+	if string(content) != sql {
+		t.Errorf("The existing repeatable migration was overwritten: %q", content)
+	}
+}
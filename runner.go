@@ -0,0 +1,161 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/javif89/migrate/database"
+)
+
+// A database to run migrations on, e.g. one per tenant
+type Tenant struct {
+	Name   string
+	Driver database.DriverName
+	Config database.Config
+}
+
+type TenantResult struct {
+	Tenant   Tenant
+	Err      error
+	Duration time.Duration
+	// The tenant was never started because an earlier one
+	// failed under FailFast or the context was cancelled
+	Skipped bool
+}
+
+// Runs migrations across many databases with bounded parallelism
+type Runner struct {
+	// Folder with the migrations, shared by every tenant
+	Path string
+	// Databases to run on
+	Tenants []Tenant
+	// Used instead of Tenants when set, e.g. to list
+	// tenants from a central database
+	Discover func(ctx context.Context) ([]Tenant, error)
+	// How many tenants to migrate at once. Defaults to 1.
+	Concurrency int
+	// Stop starting new tenants after the first failure.
+	// Otherwise every tenant is attempted.
+	FailFast bool
+	// Applied to every tenant's Migrations
+	Options []Option
+	// Tenant logs get a tenant attribute. Defaults to slog.Default()
+	Logger *slog.Logger
+}
+
+// Run Migrate on every tenant. Tenants with nothing to migrate
+// count as a success.
+func (r *Runner) Migrate(ctx context.Context) ([]TenantResult, error) {
+	return r.Run(ctx, func(m *Migrations) error {
+		err := m.Migrate()
+
+		if err == ErrNoMigrationsToRun {
+			return nil
+		}
+
+		return err
+	})
+}
+
+// Run Rollback on every tenant
+func (r *Runner) Rollback(ctx context.Context) ([]TenantResult, error) {
+	return r.Run(ctx, func(m *Migrations) error {
+		return m.Rollback()
+	})
+}
+
+// Run f on every tenant. Results are in the same order as the tenants.
+// The error is non nil if discovery or any of the tenants failed.
+func (r *Runner) Run(ctx context.Context, f func(m *Migrations) error) ([]TenantResult, error) {
+	tenants := r.Tenants
+
+	if r.Discover != nil {
+		var err error
+
+		tenants, err = r.Discover(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed discovering tenants: %w", err)
+		}
+	}
+
+	concurrency := max(r.Concurrency, 1)
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]TenantResult, len(tenants))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, t := range tenants {
+		results[i].Tenant = t
+
+		// Wait for a free slot, unless we are stopping
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+
+		if ctx.Err() != nil {
+			results[i].Skipped = true
+			results[i].Err = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(i int, t Tenant) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			start := time.Now()
+			err := r.runTenant(t, f)
+
+			results[i].Err = err
+			results[i].Duration = time.Since(start)
+
+			if err != nil && r.FailFast {
+				cancel()
+			}
+		}(i, t)
+	}
+
+	wg.Wait()
+
+	errs := []error{}
+
+	if parent.Err() != nil {
+		errs = append(errs, parent.Err())
+	}
+
+	for _, res := range results {
+		if res.Err != nil && !res.Skipped {
+			errs = append(errs, fmt.Errorf("%s: %w", res.Tenant.Name, res.Err))
+		}
+	}
+
+	return results, errors.Join(errs...)
+}
+
+func (r *Runner) runTenant(t Tenant, f func(m *Migrations) error) error {
+	logger := r.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	opts := append(append([]Option{}, r.Options...), WithLogger(logger.With("tenant", t.Name)))
+
+	m, err := Open(r.Path, t.Driver, t.Config, opts...)
+	if err != nil {
+		return err
+	}
+
+	defer m.Close()
+
+	return f(m)
+}
@@ -0,0 +1,146 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/javif89/migrate/database"
+)
+
+func sqliteTenants(dir string, n int) []Tenant {
+	tenants := []Tenant{}
+
+	for i := range n {
+		name := fmt.Sprintf("tenant%d", i)
+		tenants = append(tenants, Tenant{
+			Name:   name,
+			Driver: database.DriverSqlite,
+			Config: database.Config{Database: filepath.Join(dir, name+".sqlite")},
+		})
+	}
+
+	return tenants
+}
+
+func TestRunnerMigrate(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	writeMigration(t, mgf, "2024_01_01_000000_first", "-- UP --\ncreate table first (id int);\n-- DOWN --\ndrop table first;")
+
+	r := &Runner{
+		Path:        mgf,
+		Concurrency: 3,
+		Discover: func(ctx context.Context) ([]Tenant, error) {
+			return sqliteTenants(d, 5), nil
+		},
+	}
+
+	results, err := r.Migrate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 5 {
+		t.Fatalf("Expected 5 results, got %d", len(results))
+	}
+
+	for i, res := range results {
+		if res.Tenant.Name != fmt.Sprintf("tenant%d", i) {
+			t.Errorf("Results are not in tenant order: %s", res.Tenant.Name)
+		}
+
+		m := New(mgf, res.Tenant.Driver, res.Tenant.Config)
+		if len(m.GetExistingMigrations()) != 1 {
+			t.Errorf("%s was not migrated", res.Tenant.Name)
+		}
+		m.Close()
+	}
+
+	// Running again has nothing to do, which is not a failure
+	if _, err := r.Migrate(context.Background()); err != nil {
+		t.Errorf("Tenants with nothing to migrate should succeed: %s", err)
+	}
+
+	if _, err := r.Rollback(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunnerConcurrency(t *testing.T) {
+	var running, peak atomic.Int32
+
+	r := &Runner{
+		Tenants:     sqliteTenants(t.TempDir(), 8),
+		Concurrency: 2,
+	}
+
+	r.Run(context.Background(), func(m *Migrations) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+
+		return nil
+	})
+
+	if peak.Load() != 2 {
+		t.Errorf("Expected at most 2 tenants at once, got %d", peak.Load())
+	}
+}
+
+func TestRunnerErrors(t *testing.T) {
+	tenants := sqliteTenants(t.TempDir(), 4)
+	errBroken := errors.New("broken")
+
+	fail := func(m *Migrations) error {
+		return errBroken
+	}
+
+	// Continue on error attempts every tenant
+	r := &Runner{Tenants: tenants}
+	results, err := r.Run(context.Background(), fail)
+
+	if !errors.Is(err, errBroken) {
+		t.Errorf("Expected the tenant errors to be returned, got %v", err)
+	}
+
+	for _, res := range results {
+		if res.Skipped || res.Err != errBroken {
+			t.Errorf("Every tenant should have been attempted: %+v", res)
+		}
+	}
+
+	// Fail fast stops after the first failure
+	r = &Runner{Tenants: tenants, FailFast: true}
+	results, _ = r.Run(context.Background(), fail)
+
+	if results[0].Err != errBroken {
+		t.Errorf("First tenant should have failed: %+v", results[0])
+	}
+
+	for _, res := range results[1:] {
+		if !res.Skipped {
+			t.Errorf("%s should have been skipped", res.Tenant.Name)
+		}
+	}
+
+	r = &Runner{Discover: func(ctx context.Context) ([]Tenant, error) {
+		return nil, errBroken
+	}}
+
+	if _, err := r.Migrate(context.Background()); !errors.Is(err, errBroken) {
+		t.Errorf("Expected the discovery error, got %v", err)
+	}
+}
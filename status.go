@@ -0,0 +1,141 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ErrMissingMigration error = errors.New("applied migrations are missing on disk")
+
+// Returned by Rollback when migrations it has to roll back have no file
+// and no stored down SQL. Lists the offending migrations.
+type MissingMigrationError struct {
+	Migrations []string
+}
+
+func (e *MissingMigrationError) Error() string {
+	return fmt.Sprintf("%s: %s. Restore the files or remove them from the migrations table", ErrMissingMigration, strings.Join(e.Migrations, ", "))
+}
+
+func (e *MissingMigrationError) Unwrap() error {
+	return ErrMissingMigration
+}
+
+type MigrationStatus struct {
+	Name    string
+	Applied bool
+	// Zero when pending
+	Batch int
+	// Applied but the file is gone
+	Missing bool
+	// The down SQL was stored when it was applied, so it can
+	// be rolled back even if the file is gone
+	DownStored bool
+	// Marked -- DOWN -- irreversible
+	Irreversible bool
+	// No down SQL, rolling it back won't undo anything
+	EmptyDown bool
+	// Runs again whenever it changes
+	Repeatable bool
+	// A repeatable migration that changed since it last ran
+	Outdated bool
+}
+
+// Every migration on disk or in the migrations table, in order.
+// Without a migrations table nothing is applied.
+func (m *Migrations) Status() ([]MigrationStatus, error) {
+	files, err := m.migrationsOnDisk()
+	if err != nil {
+		return nil, err
+	}
+
+	repeatables, err := m.GetRepeatableMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := m.recordsByName()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []MigrationStatus{}
+
+	for _, mg := range files {
+		s := MigrationStatus{
+			Name:         mg.Name(),
+			Irreversible: mg.Irreversible(),
+		}
+
+		s.EmptyDown = !s.Irreversible && mg.GetDownQuery() == ""
+
+		if r, ok := records[s.Name]; ok {
+			s.Applied, s.Batch, s.DownStored = true, r.Batch, r.Down.Valid
+			delete(records, s.Name)
+		}
+
+		statuses = append(statuses, s)
+	}
+
+	for _, mg := range repeatables {
+		s := MigrationStatus{Name: mg.Name(), Repeatable: true}
+
+		sum, err := mg.Checksum()
+		if err != nil {
+			return nil, err
+		}
+
+		if r, ok := records[s.Name]; ok {
+			s.Applied, s.Outdated = true, r.Checksum.String != sum
+			delete(records, s.Name)
+		}
+
+		statuses = append(statuses, s)
+	}
+
+	// Whatever is left in the table has no file
+	for _, r := range records {
+		statuses = append(statuses, MigrationStatus{
+			Name:       r.Name,
+			Applied:    true,
+			Batch:      r.Batch,
+			Missing:    true,
+			Repeatable: isRepeatable(r.Name),
+			DownStored: r.Down.Valid,
+			EmptyDown:  r.Down.Valid && r.Down.String == "",
+		})
+	}
+
+	slices.SortStableFunc(statuses, func(a, b MigrationStatus) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return statuses, nil
+}
+
+// Get the names of applied migrations whose file is gone
+func (m *Migrations) GetMissingMigrations() ([]string, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	missing := []string{}
+
+	for _, s := range statuses {
+		if s.Missing {
+			missing = append(missing, s.Name)
+		}
+	}
+
+	return missing, nil
+}
+
+// Migrations for our driver in every environment. Applied migrations
+// for other environments are still there, not missing. An empty folder
+// is not an error, since the table can have rows for deleted files.
+func (m *Migrations) migrationsOnDisk() ([]Migration, error) {
+	return m.allMigrations()
+}
@@ -0,0 +1,184 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/javif89/migrate/database"
+)
+
+func TestStatusMissingMigrations(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	writeMigration(t, mgf, "2024_01_01_000000_users", "-- UP --\ncreate table users (id int);\n-- DOWN --\ndrop table users;")
+	writeMigration(t, mgf, "2024_01_02_000000_posts", "-- UP --\ncreate table posts (id int);\n-- DOWN --\ndrop table posts;")
+	m.Migrate()
+
+	writeMigration(t, mgf, "2024_01_03_000000_tags", "-- UP --\ncreate table tags (id int);\n-- DOWN --\ndrop table tags;")
+
+	if err := os.Remove(filepath.Join(mgf, "2024_01_02_000000_posts.sql")); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(statuses) != 3 {
+		t.Fatalf("Expected 3 migrations, got %+v", statuses)
+	}
+
+	if !statuses[0].Applied || statuses[0].Missing || statuses[0].Batch != 1 {
+		t.Errorf("Incorrect status for an applied migration: %+v", statuses[0])
+	}
+
+	if !statuses[1].Missing || statuses[1].DownStored {
+		t.Errorf("Deleted migration should be reported as missing: %+v", statuses[1])
+	}
+
+	if statuses[2].Applied {
+		t.Errorf("Incorrect status for a pending migration: %+v", statuses[2])
+	}
+
+	// Rollback can't undo a migration it has no SQL for
+	err = m.Rollback()
+
+	var missingErr *MissingMigrationError
+	if !errors.As(err, &missingErr) || !errors.Is(err, ErrMissingMigration) {
+		t.Fatalf("Expected a missing migration error, got %v", err)
+	}
+
+	if len(missingErr.Migrations) != 1 || missingErr.Migrations[0] != statuses[1].Name {
+		t.Errorf("Missing migration error does not list the migration: %v", missingErr.Migrations)
+	}
+
+	if len(m.GetExistingMigrations()) != 2 {
+		t.Errorf("Nothing in the batch should be rolled back")
+	}
+}
+
+func TestStatusWithoutMigrationsTable(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	writeMigration(t, mgf, "2024_01_01_000000_users", "-- UP --\ncreate table users (id int);\n-- DOWN --\ndrop table users;")
+
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(statuses) != 1 || statuses[0].Applied {
+		t.Errorf("Nothing should be applied without a migrations table: %+v", statuses)
+	}
+
+	if m.hasTable() {
+		t.Errorf("Status should not create the migrations table")
+	}
+}
+
+func TestStoreDownSQL(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	conn := m.driver.GetConnection()
+
+	// A table from before down SQL could be stored
+	writeMigration(t, mgf, "2024_01_01_000000_users", "-- UP --\ncreate table users (id int);\n-- DOWN --\ndrop table users;")
+	m.Migrate()
+
+	m = New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	}, WithStoreDownSQL())
+
+	writeMigration(t, mgf, "2024_01_02_000000_posts", "-- UP --\ncreate table posts (id int);\n-- DOWN --\ndrop table posts;")
+
+	if err := m.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(filepath.Join(mgf, "2024_01_02_000000_posts.sql")); err != nil {
+		t.Fatal(err)
+	}
+
+	missing, _ := m.GetMissingMigrations()
+
+	if len(missing) != 1 {
+		t.Fatalf("Expected one missing migration, got %v", missing)
+	}
+
+	if err := m.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	conn.QueryRow("select count(*) from sqlite_master where name = 'posts'").Scan(&count)
+
+	if count != 0 {
+		t.Errorf("Stored down SQL was not run")
+	}
+
+	if existing := m.GetExistingMigrations(); len(existing) != 1 {
+		t.Errorf("Missing migration should be removed from the table: %v", existing)
+	}
+}
+
+// SQLite also accepts ?NNN, so it can stand in for a driver
+// with numbered placeholders
+type numberedDriver struct {
+	database.Driver
+}
+
+func (numberedDriver) Placeholder(n int) string { return fmt.Sprintf("?%d", n) }
+
+func TestDriverPlaceholders(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	}, WithStoreDownSQL())
+
+	m.driver = numberedDriver{m.driver}
+
+	if m.placeholder(2) != "?2" {
+		t.Fatalf("The driver placeholder should be used, got %s", m.placeholder(2))
+	}
+
+	writeMigration(t, mgf, "2024_01_01_000000_create_users_table", "-- UP --\ncreate table users (id int);\n-- DOWN --\ndrop table users;")
+	writeMigration(t, mgf, "R__users_view", "-- UP --\ncreate view if not exists users_view as select id from users;\n-- DOWN --\n")
+
+	if err := m.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := m.recordsByName()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r := records["2024_01_01_000000_create_users_table"]; r.Batch != 1 || r.Down.String != "drop table users;" {
+		t.Errorf("Incorrect record: %+v", r)
+	}
+
+	if r := records["R__users_view"]; !r.Checksum.Valid {
+		t.Errorf("Incorrect record for the repeatable migration: %+v", r)
+	}
+
+	if batch := m.GetMigrationsInBatch(1); len(batch) != 1 {
+		t.Errorf("Incorrect migrations in batch 1: %v", batch)
+	}
+}
@@ -0,0 +1,201 @@
+package migrate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/javif89/migrate/database"
+)
+
+// Options for scaffolding a new migration
+type CreateOptions struct {
+	// Table the migration is for. Used as {{.Table}} in templates.
+	Table string
+	// Scaffold a CREATE TABLE instead of an ALTER TABLE for Table
+	Create bool
+	// Name of a template in the templates folder. Takes priority
+	// over the table scaffolds.
+	Template string
+	// Create a repeatable migration (R__<name>.sql)
+	Repeatable bool
+	// File with the desired schema. The migration gets the statements
+	// that turn the database into it, instead of a template.
+	FromDiff string
+}
+
+// Data available to migration templates
+type TemplateData struct {
+	Name      string // Name passed to migrate create
+	Table     string
+	Timestamp string // Same timestamp used in the file name
+	Driver    database.DriverName
+}
+
+var ErrMigrationExists error = errors.New("migration already exists")
+
+var blankTemplate = "-- UP --\n\n-- DOWN --"
+
+// Built in scaffolds per driver. Drivers without an entry
+// fall back to the blank template.
+var builtinTemplates = map[database.DriverName]map[string]string{
+	database.DriverMysql: {
+		"create": "-- UP --\n" +
+			"CREATE TABLE `{{.Table}}` (\n" +
+			"    id bigint unsigned NOT NULL AUTO_INCREMENT,\n" +
+			"    created_at timestamp NULL,\n" +
+			"    updated_at timestamp NULL,\n" +
+			"\n" +
+			"    PRIMARY KEY (id)\n" +
+			");\n" +
+			"\n" +
+			"-- DOWN --\n" +
+			"DROP TABLE IF EXISTS `{{.Table}}`;",
+		"table": "-- UP --\n" +
+			"-- ALTER TABLE `{{.Table}}` ADD COLUMN `column` varchar(255) NULL;\n" +
+			"\n" +
+			"-- DOWN --\n" +
+			"-- ALTER TABLE `{{.Table}}` DROP COLUMN `column`;",
+	},
+	database.DriverSqlite: {
+		"create": "-- UP --\n" +
+			"CREATE TABLE \"{{.Table}}\" (\n" +
+			"    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,\n" +
+			"    created_at datetime NULL,\n" +
+			"    updated_at datetime NULL\n" +
+			");\n" +
+			"\n" +
+			"-- DOWN --\n" +
+			"DROP TABLE IF EXISTS \"{{.Table}}\";",
+		"table": "-- UP --\n" +
+			"-- ALTER TABLE \"{{.Table}}\" ADD COLUMN \"column\" varchar(255) NULL;\n" +
+			"\n" +
+			"-- DOWN --\n" +
+			"-- ALTER TABLE \"{{.Table}}\" DROP COLUMN \"column\";",
+	},
+}
+
+// Create a new blank migration and return its path
+func (m *Migrations) CreateMigration(name string) (string, error) {
+	return m.CreateMigrationFrom(name, CreateOptions{})
+}
+
+// Create a new migration from a template, or from the differences
+// with a desired schema, and return its path.
+//
+// Templates are looked up in the templates folder first, as
+// <template>.<driver>.sql and then <template>.sql, falling back
+// to the built in scaffolds for the driver.
+func (m *Migrations) CreateMigrationFrom(name string, opts CreateOptions) (string, error) {
+	data := TemplateData{
+		Name:      name,
+		Table:     opts.Table,
+		Timestamp: time.Now().Format(timestampFormat),
+		Driver:    m.dialect,
+	}
+
+	filename := fmt.Sprintf("%s_%s.sql", data.Timestamp, name)
+	if opts.Repeatable {
+		filename = fmt.Sprintf("%s%s.sql", repeatablePrefix, name)
+	}
+	path := filepath.Join(m.path, filename)
+
+	// Repeatable migrations keep the same file name, don't
+	// overwrite the SQL in an existing one
+	if _, err := os.Stat(path); opts.Repeatable && err == nil {
+		return "", fmt.Errorf("%w: %s", ErrMigrationExists, path)
+	}
+
+	var content string
+	var err error
+
+	if opts.FromDiff != "" {
+		content, err = m.migrationFromDiff(opts.FromDiff)
+	} else {
+		content, err = m.scaffold(templateKind(opts), data)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	saveFile(path, content)
+
+	return path, nil
+}
+
+func (m *Migrations) scaffold(kind string, data TemplateData) (string, error) {
+	tmpl, err := m.findTemplate(kind)
+	if err != nil {
+		return "", err
+	}
+
+	t, err := template.New(data.Name).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid migration template: %w", err)
+	}
+
+	var content bytes.Buffer
+	if err := t.Execute(&content, data); err != nil {
+		return "", fmt.Errorf("invalid migration template: %w", err)
+	}
+
+	return content.String(), nil
+}
+
+// Folder user defined templates are read from
+func (m *Migrations) templatesPath() string {
+	if m.TemplatesPath != "" {
+		return m.TemplatesPath
+	}
+
+	return m.join(m.path, "templates")
+}
+
+func (m *Migrations) findTemplate(kind string) (string, error) {
+	candidates := []string{
+		m.join(m.templatesPath(), fmt.Sprintf("%s.%s.sql", kind, m.dialect)),
+		m.join(m.templatesPath(), fmt.Sprintf("%s.sql", kind)),
+	}
+
+	for _, c := range candidates {
+		content, err := m.readFile(c)
+
+		if err == nil {
+			return string(content), nil
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	if t, ok := builtinTemplates[m.dialect][kind]; ok {
+		return t, nil
+	}
+
+	switch kind {
+	case "blank", "create", "table":
+		return blankTemplate, nil
+	}
+
+	return "", fmt.Errorf("template %s not found in %s", kind, m.templatesPath())
+}
+
+func templateKind(opts CreateOptions) string {
+	switch {
+	case opts.Template != "":
+		return opts.Template
+	case opts.Table != "" && opts.Create:
+		return "create"
+	case opts.Table != "":
+		return "table"
+	}
+
+	return "blank"
+}
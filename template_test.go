@@ -0,0 +1,68 @@
+package migrate
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javif89/migrate/database"
+)
+
+func TestTemplatedMigrations(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	db := filepath.Join(d, "testdb.sqlite")
+
+	t.Setenv("MIGRATE_TEST_DEFAULT", "guest")
+
+	writeMigration(t, mgf, "2024_01_01_000000_create_users_table", `-- UP --
+create table {{ .TablePrefix }}users (name text default '{{ env "MIGRATE_TEST_DEFAULT" }}');
+-- DOWN --
+drop table {{ .TablePrefix }}users;`)
+
+	m := New(mgf, database.DriverSqlite, database.Config{Database: db}, WithVars(map[string]string{}))
+
+	if _, err := m.Plan(Up, ""); err == nil || !strings.Contains(err.Error(), "TablePrefix") {
+		t.Fatalf("Expected an error for the undefined variable, got %v", err)
+	}
+
+	m = New(mgf, database.DriverSqlite, database.Config{Database: db})
+
+	if _, err := m.Plan(Up, ""); err == nil || !strings.Contains(err.Error(), "TablePrefix") {
+		t.Fatalf("Expected an error for the undefined variable without any configured, got %v", err)
+	}
+
+	m = New(mgf, database.DriverSqlite, database.Config{Database: db}, WithVars(map[string]string{"TablePrefix": "app_"}))
+
+	p, err := m.Plan(Up, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(p.String(), "create table app_users (name text default 'guest');") {
+		t.Errorf("The plan should show the rendered SQL:\n%s", p)
+	}
+
+	mg := p.Steps[0].Migration
+	before, _ := mg.Checksum()
+
+	mg.vars = map[string]string{"TablePrefix": "other_"}
+	if after, _ := mg.Checksum(); after == before {
+		t.Errorf("The checksum should change with the variables")
+	}
+
+	if err := p.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	m.driver.GetConnection().QueryRow("select count(*) from sqlite_master where name = 'app_users'").Scan(&n)
+
+	if n != 0 {
+		t.Errorf("The rendered down section should drop the table")
+	}
+}
@@ -1,10 +1,43 @@
 package migrate
 
 import (
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 )
 
+// Read a directory from the configured file system, or the OS
+func (m *Migrations) readDir(dir string) ([]fs.DirEntry, error) {
+	if m.fsys != nil {
+		return fs.ReadDir(m.fsys, dir)
+	}
+
+	return os.ReadDir(dir)
+}
+
+// Read a file from the configured file system, or the OS
+func (m *Migrations) readFile(name string) ([]byte, error) {
+	return readFile(m.fsys, name)
+}
+
+func readFile(fsys fs.FS, name string) ([]byte, error) {
+	if fsys != nil {
+		return fs.ReadFile(fsys, name)
+	}
+
+	return os.ReadFile(name)
+}
+
+// fs.FS paths always use forward slashes
+func (m *Migrations) join(elem ...string) string {
+	if m.fsys != nil {
+		return path.Join(elem...)
+	}
+
+	return filepath.Join(elem...)
+}
+
 func createFile(path string) {
 	absolutepath, _ := filepath.Abs(path)
     // Create directories recursively
@@ -0,0 +1,88 @@
+package migrate
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javif89/migrate/database"
+)
+
+func TestDriverVariants(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	writeMigration(t, mgf, "2024_01_01_000000_create_users_table", "-- UP --\ncreate table users (id int);\n-- DOWN --\ndrop table users;")
+	writeMigration(t, mgf, "2024_01_01_000000_create_users_table.mysql", "-- UP --\ncreate table users (id bigint auto_increment primary key);\n-- DOWN --\ndrop table users;")
+	writeMigration(t, mgf, "2024_01_02_000000_create_posts_table", "-- UP --\ncreate table posts (id int);\n-- DOWN --\ndrop table posts;")
+	writeMigration(t, mgf, "2024_01_02_000000_create_posts_table.sqlite", "-- UP --\ncreate table posts (id integer primary key autoincrement);\n-- DOWN --\ndrop table posts;")
+	writeMigration(t, mgf, "2024_01_03_000000_partition_posts_table.mysql", "-- UP --\nalter table posts partition by hash(id);\n-- DOWN --\n")
+	writeMigration(t, mgf, "2024_01_04_000000_create_tags_table", `-- UP --
+create table tags (
+-- IF sqlite --
+  id integer primary key autoincrement
+-- IF mysql --
+  id bigint auto_increment primary key
+-- END IF --
+);
+-- DOWN --
+drop table tags;`)
+
+	migrations, err := m.GetMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := []string{}
+	for _, mg := range migrations {
+		paths = append(paths, filepath.Base(mg.Path))
+	}
+
+	expected := "2024_01_01_000000_create_users_table.sql,2024_01_02_000000_create_posts_table.sqlite.sql,2024_01_04_000000_create_tags_table.sql"
+
+	if strings.Join(paths, ",") != expected {
+		t.Fatalf("Incorrect variants: %v", paths)
+	}
+
+	if migrations[1].Name() != "2024_01_02_000000_create_posts_table" || migrations[1].Dialect() != database.DriverSqlite {
+		t.Errorf("Variants should share the name of the plain file: %s %s", migrations[1].Name(), migrations[1].Dialect())
+	}
+
+	up := migrations[2].GetUpQuery()
+
+	if !strings.Contains(up, "autoincrement") || strings.Contains(up, "bigint") || strings.Contains(up, "-- IF") {
+		t.Errorf("Only the sqlite section should be kept:\n%s", up)
+	}
+
+	if err := m.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnvironmentMigrations(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	db := filepath.Join(d, "testdb.sqlite")
+
+	writeMigration(t, mgf, "2024_01_01_000000_create_users_table", "-- UP --\ncreate table users (id int);\n-- DOWN --\ndrop table users;")
+	writeMigration(t, mgf, "2024_01_02_000000_seed_users_table", "-- migrate:env local,testing\n-- UP --\ninsert into users values (1);\n-- DOWN --\ndelete from users;")
+
+	local := New(mgf, database.DriverSqlite, database.Config{Database: db}, WithEnvironment("local"))
+	local.Migrate()
+
+	prod := New(mgf, database.DriverSqlite, database.Config{Database: db}, WithEnvironment("production"))
+
+	migrations, _ := prod.GetMigrations()
+
+	if len(migrations) != 1 {
+		t.Errorf("Migrations for other environments should be left out: %v", migrations)
+	}
+
+	// Applied in another environment, so not missing
+	if missing, _ := prod.GetMissingMigrations(); len(missing) != 0 {
+		t.Errorf("Migrations for other environments should not be missing: %v", missing)
+	}
+}
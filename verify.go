@@ -0,0 +1,149 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/javif89/migrate/database"
+)
+
+// What Verify found for a pending migration
+type VerifyResult struct {
+	Migration Migration
+	// How the schema after rolling it back differs from the one before
+	Differences []SchemaDifference
+	// How the schema after running it again differs from the first run
+	ReapplyDifferences []SchemaDifference
+	// It was only applied since it can't be rolled back
+	Irreversible bool
+	// Running it, rolling it back or running it again failed
+	Err error
+}
+
+func (r VerifyResult) Failed() bool {
+	return r.Err != nil || len(r.Differences) > 0 || len(r.ReapplyDifferences) > 0
+}
+
+func (r VerifyResult) String() string {
+	switch {
+	case r.Err != nil:
+		return fmt.Sprintf("FAIL %s: %v", r.Migration.Name(), r.Err)
+	case r.Failed():
+		lines := []string{"FAIL " + r.Migration.Name()}
+
+		for _, d := range r.Differences {
+			lines = append(lines, "  rolling back: "+d.String())
+		}
+
+		for _, d := range r.ReapplyDifferences {
+			lines = append(lines, "  running again: "+d.String())
+		}
+
+		return strings.Join(lines, "\n")
+	case r.Irreversible:
+		return "skip " + r.Migration.Name() + " (irreversible)"
+	}
+
+	return "ok   " + r.Migration.Name()
+}
+
+// Check that each pending migration can be rolled back, on a copy of
+// the database: it runs, is rolled back, the schema has to be the same
+// as before, and it runs again so the next one starts from there. The
+// database itself isn't touched. Stops at the first failure since the
+// copy is no longer in a known state.
+func (m *Migrations) Verify() ([]VerifyResult, error) {
+	c, ok := m.driver.(database.Copier)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s can't copy the database", database.ErrNotSupported, m.dialect)
+	}
+
+	d, cleanup, err := c.Copy()
+	if err != nil {
+		return nil, err
+	}
+
+	defer cleanup()
+
+	cp := m.on(d)
+	results := []VerifyResult{}
+
+	p, err := cp.Plan(Up, "")
+	if errors.Is(err, ErrNoMigrations) {
+		return results, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range p.Steps {
+		// Repeatable migrations are never rolled back
+		if s.Migration.Repeatable() {
+			continue
+		}
+
+		r := cp.verify(s.Migration)
+		results = append(results, r)
+
+		if r.Failed() {
+			break
+		}
+	}
+
+	return results, nil
+}
+
+// Up, down and up again for a single migration
+func (m *Migrations) verify(mg Migration) VerifyResult {
+	r := VerifyResult{Migration: mg, Irreversible: mg.Irreversible()}
+
+	before, err := m.Schema()
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	if r.Err = m.MigrateTo(mg.Name()); r.Err != nil || r.Irreversible {
+		return r
+	}
+
+	after, err := m.Schema()
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	if err := m.Rollback(); err != nil {
+		r.Err = fmt.Errorf("rolling back: %w", err)
+		return r
+	}
+
+	rolledBack, err := m.Schema()
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	// The migrations table is created by the first run, leave it out.
+	// Running it again would trip over what's left.
+	if r.Differences = DiffSchemas(before, rolledBack, m.table); len(r.Differences) > 0 {
+		return r
+	}
+
+	if err := m.MigrateTo(mg.Name()); err != nil {
+		r.Err = fmt.Errorf("running again: %w", err)
+		return r
+	}
+
+	again, err := m.Schema()
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	r.ReapplyDifferences = DiffSchemas(after, again, m.table)
+
+	return r
+}
@@ -0,0 +1,53 @@
+package migrate
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/javif89/migrate/database"
+)
+
+func TestVerify(t *testing.T) {
+	d := t.TempDir()
+	mgf := filepath.Join(d, "migrations")
+	m := New(mgf, database.DriverSqlite, database.Config{
+		Database: filepath.Join(d, "testdb.sqlite"),
+	})
+
+	writeMigration(t, mgf, "2024_01_01_000000_create_users_table", "-- UP --\ncreate table users (id int);\n-- DOWN --\ndrop table users;")
+
+	if err := m.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if results, err := m.Verify(); err != nil || len(results) != 0 {
+		t.Fatalf("Nothing is pending, got %v %v", results, err)
+	}
+
+	writeMigration(t, mgf, "2024_01_02_000000_add_email", "-- UP --\nalter table users add column email text;\n-- DOWN --\nalter table users drop column email;")
+	writeMigration(t, mgf, "2024_01_03_000000_backfill", "-- UP --\nupdate users set email = 'a';\n-- DOWN -- irreversible")
+	writeMigration(t, mgf, "2024_01_04_000000_add_name", "-- UP --\nalter table users add column name text;\ncreate index users_name on users (name);\n-- DOWN --\ndrop index users_name;")
+	writeMigration(t, mgf, "2024_01_05_000000_add_age", "-- UP --\nalter table users add column age int;\n-- DOWN --\nalter table users drop column age;")
+
+	results, err := m.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("Expected verification to stop at the broken migration, got %v", results)
+	}
+
+	if results[0].Failed() || !results[1].Irreversible || results[1].Failed() {
+		t.Errorf("Incorrect results: %v", results)
+	}
+
+	if !results[2].Failed() || len(results[2].Differences) != 1 || !strings.Contains(results[2].String(), "extra column users.name") {
+		t.Errorf("Expected the column left behind to be reported:\n%s", results[2])
+	}
+
+	if len(m.GetExistingMigrations()) != 1 {
+		t.Errorf("The database should not be touched: %v", m.GetExistingMigrations())
+	}
+}